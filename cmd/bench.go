@@ -0,0 +1,282 @@
+/*
+Copyright © 2024 lemolatoon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lemolatoon/acutils-cli/judge"
+	"github.com/lemolatoon/acutils-cli/sandbox"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// BENCH_DIR is the directory in a problem directory which has the inputs for bench.
+const BENCH_DIR = "bench"
+const BENCH_EXECUTABLE = "bench.out"
+
+const BENCH_WARN_PERCENT_KEY = "BENCH_WARN_PERCENT"
+const BENCH_WARN_PERCENT_DEFAULT = 50
+
+// GetBenchWarnPercent returns BENCH_WARN_PERCENT, the percentage of TIME_LIMIT
+// over which the median time is warned by bench.
+func GetBenchWarnPercent() int {
+	if percent := viper.GetInt(BENCH_WARN_PERCENT_KEY); percent > 0 {
+		return percent
+	}
+
+	return BENCH_WARN_PERCENT_DEFAULT
+}
+
+var (
+	benchRuns   int
+	benchInputs []string
+	benchGen    int
+)
+
+var benchCmd = &cobra.Command{
+	Use:   "bench problem-name",
+	Short: "Measure the time and memory of the solution on large inputs.",
+	Long: `Measure the time and memory of the solution on large inputs.
+
+main.cpp is compiled to bench.out with a release profile: the flags in effect
+without the sanitizers, debug flags and _GLIBCXX_DEBUG, with -O2 unless another
+optimization level is given. The inputs are the files in the bench directory of the
+problem, the --input files, or --gen inputs generated from gen.spec. Without any of
+them, the test cases are used.
+
+Each input is run --runs times in the sandbox, one at a time, and the min, median
+and max wall time and the peak memory are shown against TIME_LIMIT. A warning is
+printed when the median exceeds BENCH_WARN_PERCENT (default: 50) percent of TIME_LIMIT.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		if benchRuns < 1 {
+			return fmt.Errorf("--runs must be positive, got %d", benchRuns)
+		}
+		directory := args[0]
+
+		executable := filepath.Join(directory, BENCH_EXECUTABLE)
+		if checkIfShouldCompile(sourcePath(directory), executable) {
+			flags := releaseFlags(GetCXXFLAGS())
+			logf("compiling %s with %s\n", executable, strings.Join(flags, " "))
+			if err := compileWithFlags(sourcePath(directory), executable, flags); err != nil {
+				return err
+			}
+		}
+
+		cases, cleanup, err := benchCases(directory)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		if dryRun {
+			return nil
+		}
+
+		timeLimit := GetTimeLimit()
+		var limits sandbox.Limits
+		// Let slow runs go over the limit, to show how slow they are.
+		if sandboxed := sandboxLimits(3 * timeLimit); sandboxed != nil {
+			limits = *sandboxed
+			// The release build has no AddressSanitizer.
+			limits.Memory = GetMemoryLimit()
+		}
+
+		fmt.Printf("%-16s%10s%10s%10s%10s%8s\n", "input", "min", "median", "max", "memory", "limit")
+		var warnings []string
+		failed := false
+		for _, c := range cases {
+			stats, result, err := benchCase(commandPath(executable), c, benchRuns, 3*timeLimit, limits)
+			if err != nil {
+				return err
+			}
+			if result.Verdict != judge.OK {
+				failed = true
+				fmt.Printf("%-16s%s %s\n", c.Name, result.Verdict, result.Detail(sourcePath(directory)))
+				continue
+			}
+			percent := int(100 * stats.Median / timeLimit)
+			fmt.Printf("%-16s%10s%10s%10s%10s%7d%%\n", c.Name, formatDuration(stats.Min), formatDuration(stats.Median), formatDuration(stats.Max), formatMemory(stats.Memory), percent)
+			if percent > GetBenchWarnPercent() {
+				warnings = append(warnings, fmt.Sprintf("warning: %s: the median %s is %d%% of TIME_LIMIT %s (%s = %d)",
+					c.Name, formatDuration(stats.Median), percent, timeLimit, BENCH_WARN_PERCENT_KEY, GetBenchWarnPercent()))
+			}
+		}
+		for _, warning := range warnings {
+			fmt.Fprintln(os.Stderr, warning)
+		}
+		if failed {
+			return errors.New("the solution failed on some inputs")
+		}
+
+		return nil
+	},
+}
+
+// releaseFlags removes the sanitizers and the debug flags from the flags, adding -O2
+// if no optimization level is given.
+func releaseFlags(flags []string) []string {
+	release := make([]string, 0, len(flags)+1)
+	optimized := false
+	for _, flag := range flags {
+		switch {
+		case strings.HasPrefix(flag, "-fsanitize"), strings.HasPrefix(flag, "-fno-sanitize"),
+			flag == "-g", strings.HasPrefix(flag, "-g") && len(flag) == 3,
+			flag == "-D_GLIBCXX_DEBUG", flag == "-D_GLIBCXX_DEBUG_PEDANTIC", flag == "-D_GLIBCXX_ASSERTIONS",
+			flag == "-O0", flag == "-Og":
+			continue
+		case strings.HasPrefix(flag, "-O"):
+			optimized = true
+		}
+		release = append(release, flag)
+	}
+	if !optimized {
+		release = append(release, "-O2")
+	}
+
+	return release
+}
+
+// benchCases returns the inputs to measure. cleanup removes the generated inputs.
+func benchCases(directory string) ([]judge.Case, func(), error) {
+	cleanup := func() {}
+	var cases []judge.Case
+	for _, input := range benchInputs {
+		cases = append(cases, judge.Case{Name: filepath.Base(input), Input: input})
+	}
+	if benchGen > 0 {
+		spec, err := loadGenSpec(directory)
+		if err != nil {
+			return nil, cleanup, err
+		}
+		dir, err := os.MkdirTemp("", "acutils-bench-")
+		if err != nil {
+			return nil, cleanup, err
+		}
+		cleanup = func() { os.RemoveAll(dir) }
+		seed := time.Now().UnixNano()
+		for i := 0; i < benchGen; i++ {
+			c := judge.Case{Name: fmt.Sprintf("gen-%d", i+1), Input: filepath.Join(dir, fmt.Sprintf("%d.in", i))}
+			input, err := os.Create(c.Input)
+			if err != nil {
+				return nil, cleanup, err
+			}
+			err = spec.Generate(input, seed+int64(i))
+			input.Close()
+			if err != nil {
+				return nil, cleanup, err
+			}
+			cases = append(cases, c)
+		}
+		logf("generated gen-1..gen-%d with seeds %d..%d\n", benchGen, seed, seed+int64(benchGen)-1)
+	}
+	if len(cases) != 0 {
+		return cases, cleanup, nil
+	}
+
+	inputs, err := filepath.Glob(filepath.Join(directory, BENCH_DIR, "*"))
+	if err != nil {
+		return nil, cleanup, err
+	}
+	for _, input := range inputs {
+		if info, err := os.Stat(input); err == nil && !info.IsDir() {
+			cases = append(cases, judge.Case{Name: filepath.Base(input), Input: input})
+		}
+	}
+	if len(cases) != 0 {
+		return cases, cleanup, nil
+	}
+
+	logf("no inputs in %s, using the test cases\n", filepath.Join(directory, BENCH_DIR))
+	cases, err = judge.Discover(directory)
+	if err == nil && len(cases) == 0 {
+		err = fmt.Errorf("no inputs in %s nor %s", filepath.Join(directory, BENCH_DIR), testsDir(directory))
+	}
+	for i := range cases {
+		// The output is not checked.
+		cases[i].Output = ""
+	}
+
+	return cases, cleanup, err
+}
+
+// BenchStats are the statistics of the runs of an input.
+type BenchStats struct {
+	Min, Median, Max time.Duration
+	// Memory is the peak memory of the runs in bytes.
+	Memory int64
+}
+
+func newBenchStats(times []time.Duration, memory int64) BenchStats {
+	sorted := slices.Clone(times)
+	slices.Sort(sorted)
+	median := sorted[len(sorted)/2]
+	if len(sorted)%2 == 0 {
+		median = (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+	}
+
+	return BenchStats{Min: sorted[0], Median: median, Max: sorted[len(sorted)-1], Memory: memory}
+}
+
+// benchCase runs the input the times, stopping at the first failed run, which is returned.
+func benchCase(executable string, c judge.Case, runs int, deadline time.Duration, limits sandbox.Limits) (BenchStats, judge.Result, error) {
+	times := make([]time.Duration, 0, runs)
+	var memory int64
+	var result judge.Result
+	for i := 0; i < runs; i++ {
+		var err error
+		result, err = judge.RunCase(executable, c, deadline, limits)
+		if err != nil {
+			return BenchStats{}, result, err
+		}
+		if result.Verdict != judge.OK {
+			return BenchStats{}, result, nil
+		}
+		times = append(times, result.Time)
+		memory = max(memory, result.Memory)
+	}
+
+	return newBenchStats(times, memory), result, nil
+}
+
+// formatMemory formats the memory in MiB, e.g. "12.5 MiB", or "-" if it is unknown.
+func formatMemory(bytes int64) string {
+	if bytes == 0 {
+		return "-"
+	}
+
+	return fmt.Sprintf("%.1f MiB", float64(bytes)/(1<<20))
+}
+
+func init() {
+	rootCmd.AddCommand(benchCmd)
+	benchCmd.Flags().IntVarP(&benchRuns, "runs", "n", 5, "number of runs of each input")
+	benchCmd.Flags().StringArrayVar(&benchInputs, "input", nil, "input file to measure (repeatable)")
+	benchCmd.Flags().IntVar(&benchGen, "gen", 0, "number of inputs to generate from gen.spec")
+}
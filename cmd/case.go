@@ -0,0 +1,321 @@
+/*
+Copyright © 2024 lemolatoon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/lemolatoon/acutils-cli/judge"
+	"github.com/lemolatoon/acutils-cli/shell"
+	"github.com/spf13/cobra"
+)
+
+var caseCmd = &cobra.Command{
+	Use:   "case",
+	Short: "Add, list, show and remove the test cases of a problem.",
+	Long: `Add, list, show and remove the test cases of a problem.
+
+The cases are tests/NAME.in and tests/NAME.out in the problem directory, which
+test runs. Cases added by hand are named custom-1, custom-2 and so on.`,
+}
+
+var (
+	caseWithOutput bool
+	caseFromRun    bool
+)
+
+var caseAddCmd = &cobra.Command{
+	Use:   "add problem-name",
+	Short: "Add a custom test case, writing its input with $VISUAL or $EDITOR.",
+	Long: `Add a custom test case, writing its input with $VISUAL or $EDITOR.
+
+When the stdin is not a terminal, the input is read from it instead, e.g.
+  echo "3 1 2" | acutils-cli case add a
+
+With --with-output, the expected output is also written with the editor.
+With --from-run, the output of the current solution for the input is saved as
+the expected output, e.g. to keep the answer of a brute force solution.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		directory := args[0]
+
+		c, err := judge.NextCustomCase(directory)
+		if err != nil {
+			return err
+		}
+		if dryRun {
+			// Only show the commands, without creating the files.
+			editor := editorCommand()
+			return runner.Run(shell.Command(editor[0], append(editor[1:], c.Input)...))
+		}
+		if err := os.MkdirAll(testsDir(directory), 0755); err != nil {
+			return err
+		}
+
+		var input []byte
+		if isTerminal(os.Stdin) {
+			input, err = editCaseFile(c.Input)
+		} else {
+			input, err = io.ReadAll(os.Stdin)
+		}
+		if err == nil && len(bytes.TrimSpace(input)) == 0 {
+			err = errors.New("the input is empty, no case is added")
+		}
+		if err == nil {
+			err = os.WriteFile(c.Input, input, 0644)
+		}
+		if err == nil {
+			switch {
+			case caseFromRun:
+				err = saveOutputOfRun(directory, c)
+			case caseWithOutput:
+				err = editExpectedOutput(c)
+			}
+		}
+		if err != nil {
+			_ = os.Remove(c.Input)
+			_ = os.Remove(c.Output)
+			return err
+		}
+
+		added, err := findCase(directory, c.Name)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("added %s\n", caseListLine(added))
+		return nil
+	},
+}
+
+// editCaseFile opens the file with the editor and returns its content after editing.
+func editCaseFile(path string) ([]byte, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			return nil, err
+		}
+	}
+	editor := editorCommand()
+	if err := runner.Run(shell.Command(editor[0], append(editor[1:], path)...)); err != nil {
+		return nil, err
+	}
+
+	return os.ReadFile(path)
+}
+
+// editExpectedOutput writes the expected output of the case with the editor.
+// If it is left empty, the case is kept without the expected output.
+func editExpectedOutput(c judge.Case) error {
+	output, err := editCaseFile(c.Output)
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(output)) == 0 {
+		return os.Remove(c.Output)
+	}
+
+	return nil
+}
+
+// saveOutputOfRun runs the solution with the input of the case and saves its output as
+// the expected output. It fails without saving if the solution fails.
+func saveOutputOfRun(directory string, c judge.Case) error {
+	if err := compileIfNeeded(directory, false); err != nil {
+		return err
+	}
+
+	var stdout, stderr bytes.Buffer
+	execute := shell.Command(commandPath(executablePath(directory)))
+	execute.StdinFile = c.Input
+	execute.Stdout = &stdout
+	execute.Stderr = io.MultiWriter(os.Stderr, &stderr)
+	execute.Limits = sandboxLimits(GetTimeLimit())
+	err := runner.Run(execute)
+	if execution := judge.NewExecution(err, stderr.Bytes()); execution.RuntimeError() {
+		if err != nil {
+			return fmt.Errorf("the solution failed, the output is not saved: %w", err)
+		}
+		return fmt.Errorf("the solution failed, the output is not saved: reported by %s", execution.Report.Sanitizer)
+	}
+
+	return os.WriteFile(c.Output, stdout.Bytes(), 0644)
+}
+
+var caseListCmd = &cobra.Command{
+	Use:   "list problem-name",
+	Short: "List the test cases of the problem.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+
+		cases, err := judge.Discover(args[0])
+		if err != nil {
+			return err
+		}
+		if len(cases) == 0 {
+			fmt.Printf("no test cases in %s\n", testsDir(args[0]))
+			return nil
+		}
+		for _, c := range cases {
+			fmt.Println(caseListLine(c))
+		}
+
+		return nil
+	},
+}
+
+// caseListLine returns a line such as "custom-1   3 lines  (no output)".
+func caseListLine(c judge.Case) string {
+	input, _ := os.ReadFile(c.Input)
+	line := fmt.Sprintf("%-16s%6d lines", c.Name, countLines(input))
+	if !c.HasOutput() {
+		line += "  (no output)"
+	}
+
+	return line
+}
+
+func countLines(content []byte) int {
+	if len(content) == 0 {
+		return 0
+	}
+	lines := bytes.Count(content, []byte("\n"))
+	if content[len(content)-1] != '\n' {
+		lines++
+	}
+
+	return lines
+}
+
+var caseShowCmd = &cobra.Command{
+	Use:   "show problem-name case-name",
+	Short: "Print the input and the expected output of the test case.",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+
+		c, err := findCase(args[0], args[1])
+		if err != nil {
+			return err
+		}
+		input, err := os.ReadFile(c.Input)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("input (%s):\n%s", c.Input, indent(input))
+		if c.HasOutput() {
+			output, err := os.ReadFile(c.Output)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("output (%s):\n%s", c.Output, indent(output))
+		}
+
+		return nil
+	},
+}
+
+var caseRmCmd = &cobra.Command{
+	Use:   "rm problem-name case-name...",
+	Short: "Remove the test cases.",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+
+		// Check all the names first not to remove only some of them.
+		cases := make([]judge.Case, 0, len(args)-1)
+		for _, name := range args[1:] {
+			c, err := findCase(args[0], name)
+			if err != nil {
+				return err
+			}
+			cases = append(cases, c)
+		}
+		for _, c := range cases {
+			if err := os.Remove(c.Input); err != nil {
+				return err
+			}
+			if c.HasOutput() {
+				if err := os.Remove(c.Output); err != nil {
+					return err
+				}
+			}
+			fmt.Printf("removed %s\n", c.Name)
+		}
+
+		return nil
+	},
+}
+
+// findCase finds the case with the name, which may also be given as a path such as tests/custom-1.in.
+func findCase(directory string, name string) (judge.Case, error) {
+	name = strings.TrimSuffix(strings.TrimSuffix(filepath.Base(name), ".in"), ".out")
+	cases, err := judge.Discover(directory)
+	if err != nil {
+		return judge.Case{}, err
+	}
+	names := make([]string, len(cases))
+	var numbered []judge.Case
+	for i, c := range cases {
+		if c.Name == name {
+			return c, nil
+		}
+		names[i] = c.Name
+		if strings.HasSuffix(c.Name, "-"+name) {
+			numbered = append(numbered, c)
+		}
+	}
+	// A number such as 3 is the case ending with it, e.g. sample-3.
+	if _, err := strconv.Atoi(name); err == nil {
+		switch len(numbered) {
+		case 1:
+			return numbered[0], nil
+		case 0:
+		default:
+			numberedNames := make([]string, len(numbered))
+			for i, c := range numbered {
+				numberedNames[i] = c.Name
+			}
+			return judge.Case{}, fmt.Errorf("test case %s is ambiguous: %s", name, strings.Join(numberedNames, ", "))
+		}
+	}
+	if suggestion := suggest(name, names); suggestion != "" {
+		return judge.Case{}, fmt.Errorf("no test case %q in %s (did you mean %s?)", name, testsDir(directory), suggestion)
+	}
+
+	return judge.Case{}, fmt.Errorf("no test case %q in %s", name, testsDir(directory))
+}
+
+func init() {
+	rootCmd.AddCommand(caseCmd)
+	caseCmd.AddCommand(caseAddCmd, caseListCmd, caseShowCmd, caseRmCmd)
+	caseAddCmd.Flags().BoolVar(&caseWithOutput, "with-output", false, "also write the expected output with the editor")
+	caseAddCmd.Flags().BoolVar(&caseFromRun, "from-run", false, "save the output of the current solution as the expected output")
+	caseAddCmd.MarkFlagsMutuallyExclusive("with-output", "from-run")
+}
@@ -24,9 +24,12 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 
 	"github.com/hairyhenderson/go-which"
+	"github.com/lemolatoon/acutils-cli/history"
 	"github.com/lemolatoon/acutils-cli/shell"
 	"github.com/spf13/cobra"
 )
@@ -42,30 +45,44 @@ var clipCmd = &cobra.Command{
 		}
 
 		problemName := args[0]
-		return clip(problemName)
+		copied, err := clip(problemName)
+		if err != nil {
+			return err
+		}
+		if copied {
+			recordEvent(problemName, history.Event{Kind: history.EVENT_CLIP})
+		}
+
+		return nil
 	},
 }
 
-func clip(problemName string) error {
+// clip copies the source code to the clipboard and reports whether it was copied.
+// Without a clipboard command, the source code is printed to be copied manually.
+func clip(problemName string) (bool, error) {
 	sourceFilePath := filepath.Join(problemName, "main.cpp")
 
 	if which.Found("clip.exe") {
-		if err := shell.Run(fmt.Sprintf("clip.exe < %s", sourceFilePath)); err != nil {
-			return err
+		copyCmd := shell.Command("clip.exe")
+		copyCmd.StdinFile = sourceFilePath
+		if err := runner.Run(copyCmd); err != nil {
+			return false, err
 		}
-		return nil
+		return true, nil
 	}
 
 	if which.Found("pbcopy") {
-		if err := shell.Run(fmt.Sprintf("pbcopy < %s", sourceFilePath)); err != nil {
-			return err
+		copyCmd := shell.Command("pbcopy")
+		copyCmd.StdinFile = sourceFilePath
+		if err := runner.Run(copyCmd); err != nil {
+			return false, err
 		}
-		return nil
+		return true, nil
 	}
 
 	content, err := os.ReadFile(sourceFilePath)
 	if err != nil {
-		return err
+		return false, err
 	}
 	fmt.Printf(`we cannot find the way to copy to the clipboard.
 Please copy the source code manually.
@@ -73,7 +90,41 @@ Please copy the source code manually.
 %s
 ################################################################`, content)
 
-	return nil
+	return false, nil
+}
+
+// clipboardReaders are the commands to read the clipboard, in the order of preference.
+var clipboardReaders = [][]string{
+	{"pbpaste"},
+	{"powershell.exe", "-NoProfile", "-Command", "Get-Clipboard"},
+	{"wl-paste", "--no-newline"},
+	{"xclip", "-selection", "clipboard", "-o"},
+	{"xsel", "--clipboard", "--output"},
+}
+
+// readClipboard returns the content of the clipboard with the first available command.
+func readClipboard() (string, error) {
+	for _, reader := range clipboardReaders {
+		if !which.Found(reader[0]) {
+			continue
+		}
+		output, err := exec.Command(reader[0], reader[1:]...).Output()
+		if err != nil {
+			return "", fmt.Errorf("failed to read the clipboard with %s: %w", reader[0], err)
+		}
+		// The clipboard of Windows has CRLF line endings.
+		return strings.ReplaceAll(string(output), "\r\n", "\n"), nil
+	}
+
+	return "", fmt.Errorf("we cannot find the way to read the clipboard (tried %s)", clipboardReaderNames())
+}
+
+func clipboardReaderNames() string {
+	names := make([]string, 0, len(clipboardReaders))
+	for _, reader := range clipboardReaders {
+		names = append(names, reader[0])
+	}
+	return strings.Join(names, ", ")
 }
 
 func init() {
@@ -2,20 +2,39 @@ package cmd
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
+	"github.com/lemolatoon/acutils-cli/history"
+	"github.com/lemolatoon/acutils-cli/judge"
+	"github.com/lemolatoon/acutils-cli/shell"
 	"github.com/spf13/viper"
 )
 
 func resetViperState(t *testing.T) {
 	t.Helper()
 	viper.Reset()
-	templatePath = ""
+	cfgFile = ""
+	templatePath, templateSet = "", ""
+	runInputPath, runFromClipboard, runStdin, runOutputPath = "", false, "", ""
+	projectConfigFile, projectConfigKeys = "", map[string]bool{}
+	dryRun, quiet, verbose, noSandbox = false, false, false, false
+	caseWithOutput, caseFromRun = false, false
+	genSeed, genSpec, genSave = 0, "", false
+	benchInputs, benchGen = nil, 0
+	debugCase, debugBacktrace, debugDebugger = "", false, ""
+	testFormat, testSplit, testNaive, testBacktrace = string(judge.FORMAT_TEXT), "", "", false
+	judgePreset = nil
+	runner = shell.ExecRunner{Trace: os.Stdout}
 }
 
 func TestGetTemplateFileContentUsesDefaultTemplateFile(t *testing.T) {
@@ -33,7 +52,10 @@ func TestGetTemplateFileContentUsesDefaultTemplateFile(t *testing.T) {
 		t.Fatalf("failed to write default template: %v", err)
 	}
 
-	got := GetTemplateFileContent()
+	got, err := GetTemplateFileContent()
+	if err != nil {
+		t.Fatalf("failed to get template: %v", err)
+	}
 	if got != want {
 		t.Fatalf("template content mismatch:\nwant: %q\ngot : %q", want, got)
 	}
@@ -51,7 +73,7 @@ func TestGetTemplateFileContentUsesExplicitTemplatePath(t *testing.T) {
 
 	viper.Set(TEMPLATE_FILE_KEY, templateFile)
 
-	if got := GetTemplateFileContent(); got != want {
+	if got, err := GetTemplateFileContent(); err != nil || got != want {
 		t.Fatalf("template content mismatch:\nwant: %q\ngot : %q", want, got)
 	}
 }
@@ -213,8 +235,8 @@ func TestClipFallsBackWithoutClipboardCommand(t *testing.T) {
 		os.Stdout = origStdout
 	}()
 
-	if err := clip("p1"); err != nil {
-		t.Fatalf("clip failed: %v", err)
+	if copied, err := clip("p1"); err != nil || copied {
+		t.Fatalf("expected clip to fall back without copying: %v %v", copied, err)
 	}
 
 	if err := w.Close(); err != nil {
@@ -229,3 +251,1167 @@ func TestClipFallsBackWithoutClipboardCommand(t *testing.T) {
 		t.Fatalf("expected fallback output to include source content")
 	}
 }
+
+func TestParseCompilerVersion(t *testing.T) {
+	cases := []struct {
+		output string
+		want   CompilerVersion
+	}{
+		{"g++ (Debian 12.2.0-14) 12.2.0\nCopyright (C) 2022\n", CompilerVersion{Family: "gcc", Version: "12.2.0", Major: 12}},
+		{"Apple clang version 15.0.0 (clang-1500.3.9.4)\nTarget: arm64\n", CompilerVersion{Family: "clang", Version: "15.0.0", Major: 15}},
+		{"Ubuntu clang version 16.0.6 (15)\n", CompilerVersion{Family: "clang", Version: "16.0.6", Major: 16}},
+	}
+	for _, c := range cases {
+		got, err := parseCompilerVersion(c.output)
+		if err != nil {
+			t.Fatalf("failed to parse %q: %v", c.output, err)
+		}
+		if got != c.want {
+			t.Fatalf("version mismatch for %q:\nwant: %+v\ngot : %+v", c.output, c.want, got)
+		}
+	}
+
+	preset := JUDGE_PRESETS["atcoder-gcc"]
+	if warning := checkJudgeCompiler(&preset, CompilerVersion{Family: "gcc", Version: "12.3.0", Major: 12}); warning != "" {
+		t.Fatalf("did not expect warning for the same major version: %s", warning)
+	}
+	if warning := checkJudgeCompiler(&preset, CompilerVersion{Family: "clang", Version: "15.0.0", Major: 15}); warning == "" {
+		t.Fatalf("expected warning for a different compiler")
+	}
+}
+
+func TestGetCXXFLAGSUsesJudgePreset(t *testing.T) {
+	resetViperState(t)
+	t.Setenv("CXX", "")
+
+	viper.Set(JUDGE_PRESET_KEY, "atcoder-gcc")
+	if err := resolveJudgePreset(); err != nil {
+		t.Fatalf("failed to resolve the preset: %v", err)
+	}
+	preset := JUDGE_PRESETS["atcoder-gcc"]
+
+	if got := GetCXX(); got != preset.CXX {
+		t.Fatalf("compiler mismatch: want %q, got %q", preset.CXX, got)
+	}
+
+	flags := strings.Join(GetCXXFLAGS(), " ")
+	for _, want := range []string{"-DONLINE_JUDGE", "-I/opt/ac-library"} {
+		if !strings.Contains(flags, want) {
+			t.Fatalf("expected %q in flags: %s", want, flags)
+		}
+	}
+	for _, flag := range GetCXXFLAGS() {
+		if strings.HasPrefix(flag, "-l") || strings.HasPrefix(flag, "-L") {
+			t.Fatalf("expected no link flags in CXXFLAGS, which go to compile_flags.txt: %s", flags)
+		}
+	}
+	if !reflect.DeepEqual(GetLinkFlags(), preset.LinkFlags) {
+		t.Fatalf("link flags mismatch: want %q, got %q", preset.LinkFlags, GetLinkFlags())
+	}
+
+	viper.Set(INCLUDE_PATHS_KEY, []string{"/home/me/ac-library"})
+	flags = strings.Join(GetCXXFLAGS(), " ")
+	if !strings.Contains(flags, "-I/home/me/ac-library") || strings.Contains(flags, "-I/opt/ac-library") {
+		t.Fatalf("expected INCLUDE_PATHS to replace the judge include paths: %s", flags)
+	}
+}
+
+func TestIgnoreWatchEvent(t *testing.T) {
+	cases := map[string]bool{
+		"a/main.cpp":        false,
+		"a/tests/new.in":    false,
+		"a/a.out":           true,
+		"a/compile.log":     true,
+		"a/4913":            true,
+		"a/main.cpp~":       true,
+		"a/.main.cpp.swp":   true,
+		"a/#main.cpp#":      true,
+		"a/main.cpp.tmp":    true,
+		"a/main.cpp___jb_x": true,
+	}
+	for name, want := range cases {
+		if got := ignoreWatchEvent(fsnotify.Event{Name: name, Op: fsnotify.Write}); got != want {
+			t.Fatalf("ignoreWatchEvent(%q) = %v, want %v", name, got, want)
+		}
+	}
+	if !ignoreWatchEvent(fsnotify.Event{Name: "a/main.cpp", Op: fsnotify.Chmod}) {
+		t.Fatalf("expected chmod events to be ignored")
+	}
+}
+
+func TestRunInputFromFlags(t *testing.T) {
+	resetViperState(t)
+
+	if got := unescapeInput(`3\n1 2 3`); got != "3\n1 2 3\n" {
+		t.Fatalf("unexpected unescaped input: %q", got)
+	}
+	if got := unescapeInput(`a\\n\tb\n`); got != "a\\n\tb\n" {
+		t.Fatalf("unexpected unescaped input: %q", got)
+	}
+
+	runStdin = `2\n5 7`
+	stdin, err := runInput()
+	if err != nil {
+		t.Fatalf("runInput failed: %v", err)
+	}
+	content, _ := io.ReadAll(stdin)
+	if string(content) != "2\n5 7\n" {
+		t.Fatalf("unexpected stdin: %q", content)
+	}
+
+	resetViperState(t)
+	inputFile := filepath.Join(t.TempDir(), "sample-2.in")
+	if err := os.WriteFile(inputFile, []byte("10\n"), 0o644); err != nil {
+		t.Fatalf("failed to write input: %v", err)
+	}
+	runInputPath = inputFile
+	stdin, err = runInput()
+	if err != nil {
+		t.Fatalf("runInput failed: %v", err)
+	}
+	content, _ = io.ReadAll(stdin)
+	if string(content) != "10\n" {
+		t.Fatalf("unexpected stdin: %q", content)
+	}
+}
+
+func TestRenderTemplateWithProblemMetadata(t *testing.T) {
+	resetViperState(t)
+	viper.Set(AUTHOR_KEY, "lemolatoon")
+	viper.Set(TEMPLATE_VARS_KEY, map[string]any{"mod": 998244353})
+
+	data := newTemplateData(filepath.Join(t.TempDir(), "abc348", "a"))
+	content := "// {{.URL}} by {{.Author}}\n#define MOD {{.Vars.mod}}\n// TL: {{.TimeLimit}}\n"
+	got, err := RenderTemplate("template.cpp", content, data)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	want := "// https://atcoder.jp/contests/abc348/tasks/abc348_a by lemolatoon\n#define MOD 998244353\n// TL: 2s\n"
+	if got != want {
+		t.Fatalf("rendered template mismatch:\nwant: %q\ngot : %q", want, got)
+	}
+}
+
+func TestRenderTemplateErrorPointsAtLine(t *testing.T) {
+	resetViperState(t)
+
+	content := "#include <bits/stdc++.h>\n// {{.Contest}}\n#define X {{.Vars.missing}}\n"
+	_, err := RenderTemplate("template.cpp", content, newTemplateData("a"))
+	if err == nil {
+		t.Fatalf("expected an error for the missing value")
+	}
+	if !strings.Contains(err.Error(), "line 3") || !strings.Contains(err.Error(), "#define X {{.Vars.missing}}") {
+		t.Fatalf("expected the error to point at line 3: %v", err)
+	}
+}
+
+func TestNewCmdCopiesTemplateSet(t *testing.T) {
+	resetViperState(t)
+
+	tmp := t.TempDir()
+	t.Setenv("HOME", tmp)
+
+	setDir := filepath.Join(tmp, ".acutils-cli", "templates", "heavy")
+	files := map[string]struct {
+		content string
+		mode    os.FileMode
+	}{
+		"main.cpp.tmpl": {"// {{.Problem}}\n", 0o644},
+		"naive.cpp":     {"vector<pair<int, int>> d = {{0, 1}, {1, 0}};\n", 0o644},
+		"gen.sh":        {"#!/bin/sh\necho 1\n", 0o755},
+		".clang-format": {"BasedOnStyle: Google\n", 0o644},
+		"lib/util.hpp":  {"#pragma once\n", 0o644},
+	}
+	for name, file := range files {
+		path := filepath.Join(setDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(file.content), file.mode); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	origWD, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get wd: %v", err)
+	}
+	defer func() {
+		_ = os.Chdir(origWD)
+	}()
+	if err := os.Chdir(tmp); err != nil {
+		t.Fatalf("failed to chdir: %v", err)
+	}
+
+	templateSet = "heavy"
+	if err := newCmd.RunE(newCmd, []string{"b"}); err != nil {
+		t.Fatalf("new command failed: %v", err)
+	}
+
+	for name, file := range files {
+		path := filepath.Join(tmp, "b", strings.TrimSuffix(name, TEMPLATE_SUFFIX))
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("%s missing: %v", name, err)
+		}
+		if info.Mode().Perm() != file.mode {
+			t.Fatalf("mode mismatch for %s: want %v, got %v", name, file.mode, info.Mode().Perm())
+		}
+	}
+	content, err := os.ReadFile(filepath.Join(tmp, "b", "main.cpp"))
+	if err != nil {
+		t.Fatalf("main.cpp missing: %v", err)
+	}
+	if string(content) != "// b\n" {
+		t.Fatalf("main.cpp is not rendered: %q", content)
+	}
+	// Files without .tmpl are not parsed as templates.
+	content, err = os.ReadFile(filepath.Join(tmp, "b", "naive.cpp"))
+	if err != nil || string(content) != files["naive.cpp"].content {
+		t.Fatalf("naive.cpp is not copied as it is: %q (%v)", content, err)
+	}
+
+	templateSet = "missing"
+	if err := newCmd.RunE(newCmd, []string{"c"}); err == nil || !strings.Contains(err.Error(), "heavy") {
+		t.Fatalf("expected an error listing the available template sets, got %v", err)
+	}
+}
+
+func TestNewCmdSelectsTemplateByRules(t *testing.T) {
+	resetViperState(t)
+
+	tmp := t.TempDir()
+	t.Setenv("HOME", tmp)
+
+	configDir := filepath.Join(tmp, ".acutils-cli")
+	if err := os.MkdirAll(filepath.Join(configDir, "templates", "heavy"), 0o755); err != nil {
+		t.Fatalf("failed to create template set: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, "templates", "heavy", "main.cpp"), []byte("// heavy\n"), 0o644); err != nil {
+		t.Fatalf("failed to write template: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, "minimal.cpp"), []byte("// minimal\n"), 0o644); err != nil {
+		t.Fatalf("failed to write template: %v", err)
+	}
+	config := `
+[[TEMPLATE_RULES]]
+pattern = "abc*/[a-b]"
+template = "minimal.cpp"
+
+[[TEMPLATE_RULES]]
+pattern = "agc*/*"
+template_set = "heavy"
+`
+	configFile := filepath.Join(configDir, "config.toml")
+	if err := os.WriteFile(configFile, []byte(config), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	viper.SetConfigFile(configFile)
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatalf("failed to read config: %v", err)
+	}
+
+	cases := []struct {
+		contest string
+		problem string
+		want    string
+	}{
+		{"abc348", "a", "// minimal\n"},
+		{"abc348", "B", "// minimal\n"},
+		{"abc348", "c", TEMPLATE_DEFAULT},
+		{"agc066", "a", "// heavy\n"},
+	}
+	for _, c := range cases {
+		files, err := GetTemplateFiles(c.contest, c.problem)
+		if err != nil {
+			t.Fatalf("GetTemplateFiles(%q, %q) failed: %v", c.contest, c.problem, err)
+		}
+		if len(files) != 1 || string(files[0].Content) != c.want {
+			t.Fatalf("template mismatch for %s/%s:\nwant: %q\ngot : %+v", c.contest, c.problem, c.want, files)
+		}
+	}
+}
+
+func TestConfigSetKeepsFileFormatting(t *testing.T) {
+	resetViperState(t)
+
+	configFile := filepath.Join(t.TempDir(), "config.toml")
+	original := "# compiler\nCXX = \"g++-12\" # judge\n\n[TEMPLATE_VARS]\nmod = 998244353\n"
+	if err := os.WriteFile(configFile, []byte(original), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	cfgFile = configFile
+
+	if err := configSetCmd.RunE(configSetCmd, []string{"cxx", "clang++"}); err != nil {
+		t.Fatalf("config set failed: %v", err)
+	}
+	if err := configSetCmd.RunE(configSetCmd, []string{"CXXFLAGS", "-O2", "-Wall"}); err != nil {
+		t.Fatalf("config set failed: %v", err)
+	}
+	if err := configSetCmd.RunE(configSetCmd, []string{"TIME_LIMIT", "two seconds"}); err == nil {
+		t.Fatalf("expected an error for an invalid duration")
+	}
+
+	content, err := os.ReadFile(configFile)
+	if err != nil {
+		t.Fatalf("failed to read config: %v", err)
+	}
+	want := "# compiler\nCXX = \"clang++\" # judge\nCXXFLAGS = [\"-O2\", \"-Wall\"]\n\n[TEMPLATE_VARS]\nmod = 998244353\n"
+	if string(content) != want {
+		t.Fatalf("config mismatch:\nwant: %q\ngot : %q", want, string(content))
+	}
+
+	viper.SetConfigFile(configFile)
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatalf("failed to read config: %v", err)
+	}
+	key, _ := lookupConfigKey("CXXFLAGS")
+	if origin := configOrigin(key); origin != "config file" {
+		t.Fatalf("unexpected origin: %s", origin)
+	}
+	t.Setenv("CXXFLAGS", "-O3")
+	if origin := configOrigin(key); origin != "env CXXFLAGS" {
+		t.Fatalf("unexpected origin: %s", origin)
+	}
+}
+
+func TestProjectConfigOverridesGlobalConfig(t *testing.T) {
+	resetViperState(t)
+
+	tmp := t.TempDir()
+	t.Setenv("HOME", tmp)
+	t.Setenv("CXX", "")
+	acutilsDir := filepath.Join(tmp, ".acutils-cli")
+	if err := os.MkdirAll(acutilsDir, 0o755); err != nil {
+		t.Fatalf("failed to create config dir: %v", err)
+	}
+	global := "CXX = \"g++\"\nAUTHOR = \"global\"\n"
+	if err := os.WriteFile(filepath.Join(acutilsDir, "config.toml"), []byte(global), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	repo := filepath.Join(tmp, "repo")
+	contest := filepath.Join(repo, "abc300")
+	if err := os.MkdirAll(contest, 0o755); err != nil {
+		t.Fatalf("failed to create contest dir: %v", err)
+	}
+	project := "CXX = \"clang++\"\nTEMPLATE_FILE = \"templates/main.cpp\"\nINCLUDE_PATHS = [\"lib\"]\n\n" +
+		"[[TEMPLATE_RULES]]\npattern = \"*\"\ntemplate = \"templates/rule.cpp\"\n"
+	if err := os.WriteFile(filepath.Join(repo, PROJECT_CONFIG_FILE), []byte(project), 0o644); err != nil {
+		t.Fatalf("failed to write project config: %v", err)
+	}
+
+	origWD, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get wd: %v", err)
+	}
+	defer os.Chdir(origWD)
+	if err := os.Chdir(contest); err != nil {
+		t.Fatalf("failed to chdir: %v", err)
+	}
+
+	initConfig()
+
+	if got := GetCXX(); got != "clang++" {
+		t.Fatalf("CXX = %q, want clang++", got)
+	}
+	if got := viper.GetString(AUTHOR_KEY); got != "global" {
+		t.Fatalf("AUTHOR = %q, want global", got)
+	}
+	if got, want := viper.GetString(TEMPLATE_FILE_KEY), filepath.Join(repo, "templates", "main.cpp"); got != want {
+		t.Fatalf("TEMPLATE_FILE = %q, want %q", got, want)
+	}
+	if got, want := GetIncludePaths(), []string{filepath.Join(repo, "lib")}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("INCLUDE_PATHS = %v, want %v", got, want)
+	}
+	rules, err := GetTemplateRules()
+	if err != nil || len(rules) != 1 {
+		t.Fatalf("unexpected rules: %v, %v", rules, err)
+	}
+	if want := filepath.Join(repo, "templates", "rule.cpp"); rules[0].Template != want {
+		t.Fatalf("rule template = %q, want %q", rules[0].Template, want)
+	}
+
+	key, _ := lookupConfigKey(CXX_KEY)
+	if origin := configOrigin(key); origin != "project config" {
+		t.Fatalf("unexpected origin: %s", origin)
+	}
+	key, _ = lookupConfigKey(AUTHOR_KEY)
+	if origin := configOrigin(key); origin != "config file" {
+		t.Fatalf("unexpected origin: %s", origin)
+	}
+}
+
+func TestValidateConfigFile(t *testing.T) {
+	resetViperState(t)
+
+	tmp := t.TempDir()
+	t.Setenv("HOME", tmp)
+	configFile := filepath.Join(tmp, "config.toml")
+	content := "CXXFLAG = [\"-O2\"]\nTIME_LIMIT = \"2\"\nTEMPLATE_FILE = \"missing.cpp\"\n\n" +
+		"[[TEMPLATE_RULES]]\npattern = \"abc*\"\ntemplate = \"a.cpp\"\ntemplate_set = \"heavy\"\n"
+	if err := os.WriteFile(configFile, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	problems, err := validateConfigFile(configFile, true)
+	if err != nil {
+		t.Fatalf("validate failed: %v", err)
+	}
+	want := []string{
+		configFile + `:1: warning: unknown key "CXXFLAG" (did you mean CXXFLAGS?)`,
+		configFile + `:2: error: TIME_LIMIT: invalid duration "2" (e.g. "2s", "500ms")`,
+		configFile + ":3: error: TEMPLATE_FILE: " + filepath.Join(tmp, "missing.cpp") + " does not exist",
+		configFile + ":5: error: TEMPLATE_RULES: rule 1: set either template or template_set",
+	}
+	got := make([]string, len(problems))
+	for i, problem := range problems {
+		got[i] = problem.String()
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("problems mismatch:\nwant: %q\ngot : %q", want, got)
+	}
+
+	if problems, _ := validateConfigFile(configFile, false); len(problems) != 3 {
+		t.Fatalf("expected no file checks, got %v", problems)
+	}
+}
+
+func TestStrictModeFailsOnMissingTemplateFile(t *testing.T) {
+	resetViperState(t)
+	// The fallback would read $HOME/.acutils-cli/template.cpp.
+	t.Setenv("HOME", t.TempDir())
+
+	viper.Set(TEMPLATE_FILE_KEY, filepath.Join(t.TempDir(), "missing.cpp"))
+	if got, err := GetTemplateFileContent(); err != nil || got != TEMPLATE_DEFAULT {
+		t.Fatalf("expected the fallback template, got %q, %v", got, err)
+	}
+
+	viper.Set(STRICT_KEY, true)
+	if _, err := GetTemplateFileContent(); err == nil {
+		t.Fatalf("expected an error in the strict mode")
+	}
+	viper.Set(VSCODE_TEMPLATE_SETTINGS_FILE_KEY, "missing.json")
+	if _, err := GetVscodeSettingsFileContent(); err == nil {
+		t.Fatalf("expected an error in the strict mode")
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	cases := map[string]string{
+		"CXXFLAG":      "CXXFLAGS",
+		"templatefile": "TEMPLATE_FILE",
+		"time_limt":    "TIME_LIMIT",
+		"COLOR":        "",
+	}
+	for name, want := range cases {
+		if got := suggest(name, configKeyNames()); got != want {
+			t.Errorf("suggest(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestDoctorReportsMissingCompiler(t *testing.T) {
+	resetViperState(t)
+	t.Setenv("HOME", t.TempDir())
+	viper.Set(CXX_KEY, "acutils-no-such-compiler")
+
+	checks := runDoctorChecks()
+	statuses := map[string]DoctorStatus{}
+	for _, check := range checks {
+		statuses[check.Name] = check.Status
+	}
+	if statuses["compiler"] != DOCTOR_FAIL {
+		t.Fatalf("expected the compiler check to fail: %+v", checks)
+	}
+	if statuses["bits/stdc++.h"] != DOCTOR_WARN || statuses["sanitizers"] != DOCTOR_WARN {
+		t.Fatalf("expected the compile checks to be skipped: %+v", checks)
+	}
+	if statuses["config"] != DOCTOR_PASS {
+		t.Fatalf("expected no config file to pass: %+v", checks)
+	}
+
+	var out bytes.Buffer
+	printDoctorReport(&out, checks)
+	report := out.String()
+	if !strings.Contains(report, "[FAIL] compiler       acutils-no-such-compiler is not found\n") ||
+		!strings.Contains(report, "hint: install g++ or clang++") ||
+		!strings.HasSuffix(report, "1 failed\n") {
+		t.Fatalf("unexpected report:\n%s", report)
+	}
+}
+
+func TestRunCmdHandlesHostileProblemDirectory(t *testing.T) {
+	resetViperState(t)
+	if _, err := exec.LookPath(GetCXX()); err != nil {
+		t.Skipf("compiler is not available: %v", err)
+	}
+	viper.Set(CXXFLAGS_KEY, []string{"-O0"})
+
+	tmp := t.TempDir()
+	t.Setenv("HOME", tmp)
+	origWD, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get wd: %v", err)
+	}
+	defer os.Chdir(origWD)
+	if err := os.Chdir(tmp); err != nil {
+		t.Fatalf("failed to chdir: %v", err)
+	}
+
+	directory := `a b; touch pwned $(touch pwned2) 'c`
+	if err := os.Mkdir(directory, 0o755); err != nil {
+		t.Fatalf("failed to create problem dir: %v", err)
+	}
+	source := "#include <cstdio>\nint main() { int x; if (std::scanf(\"%d\", &x) != 1) return 1; return x == 42 ? 0 : 1; }\n"
+	if err := os.WriteFile(filepath.Join(directory, "main.cpp"), []byte(source), 0o644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+
+	runStdin = "42"
+	if err := runCmd.RunE(runCmd, []string{directory}); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(directory, "a.out")); err != nil {
+		t.Fatalf("expected the executable in the problem dir: %v", err)
+	}
+	for _, name := range []string{"pwned", "pwned2"} {
+		if _, err := os.Stat(name); err == nil {
+			t.Fatalf("the path was interpreted by a shell: %s exists", name)
+		}
+	}
+}
+
+func TestCommandsUseInjectedRunner(t *testing.T) {
+	resetViperState(t)
+	viper.Set(CXX_KEY, "acutils-no-such-compiler")
+	viper.Set(CXXFLAGS_KEY, []string{"-O2"})
+
+	tmp := t.TempDir()
+	t.Setenv("HOME", tmp)
+	origWD, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get wd: %v", err)
+	}
+	defer os.Chdir(origWD)
+	if err := os.Chdir(tmp); err != nil {
+		t.Fatalf("failed to chdir: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join("my problem", "tests"), 0o755); err != nil {
+		t.Fatalf("failed to create problem dir: %v", err)
+	}
+	for _, file := range []string{"main.cpp", "tests/1.in"} {
+		if err := os.WriteFile(filepath.Join("my problem", file), nil, 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", file, err)
+		}
+	}
+
+	// Pretend that clip.exe exists.
+	bin := filepath.Join(tmp, "bin")
+	if err := os.Mkdir(bin, 0o755); err != nil {
+		t.Fatalf("failed to create bin: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(bin, "clip.exe"), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatalf("failed to write clip.exe: %v", err)
+	}
+	t.Setenv("PATH", bin)
+
+	recorder := &shell.Recorder{}
+	runner = recorder
+	dryRun = true
+	runStdin = "1"
+	if err := runCmd.RunE(runCmd, []string{"my problem"}); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if err := testCmd.RunE(testCmd, []string{"my problem"}); err != nil {
+		t.Fatalf("test failed: %v", err)
+	}
+	if _, err := clip("my problem"); err != nil {
+		t.Fatalf("clip failed: %v", err)
+	}
+
+	want := []string{
+		"acutils-no-such-compiler 'my problem/main.cpp' -O2 -o 'my problem/a.out'",
+		"'./my problem/a.out'",
+		"acutils-no-such-compiler 'my problem/main.cpp' -O2 -o 'my problem/a.out'",
+		"'./my problem/a.out' < 'my problem/tests/1.in'",
+		"clip.exe < 'my problem/main.cpp'",
+	}
+	if got := recorder.Lines(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("commands mismatch:\nwant: %q\ngot : %q", want, got)
+	}
+	if _, err := os.Stat(filepath.Join("my problem", COMPILE_LOG_FILE)); err == nil {
+		t.Fatalf("did not expect a compile log in the dry-run mode")
+	}
+}
+
+func TestSandboxLimitsSkipMemoryLimitWithAddressSanitizer(t *testing.T) {
+	resetViperState(t)
+	viper.Set(MEMORY_LIMIT_KEY, "512MB")
+
+	viper.Set(CXXFLAGS_KEY, []string{"-O2"})
+	limits := sandboxLimits(time.Second)
+	if limits == nil || limits.Memory != 512<<20 || limits.CPUTime != time.Second || limits.Processes != PROCESS_LIMIT_DEFAULT {
+		t.Fatalf("unexpected limits: %+v", limits)
+	}
+
+	viper.Set(CXXFLAGS_KEY, []string{"-g", "-fsanitize=undefined,address"})
+	if limits := sandboxLimits(0); limits == nil || limits.Memory != 0 {
+		t.Fatalf("expected no memory limit with AddressSanitizer: %+v", limits)
+	}
+
+	noSandbox = true
+	if limits := sandboxLimits(0); limits != nil {
+		t.Fatalf("expected no limits with --no-sandbox: %+v", limits)
+	}
+}
+
+func TestCaseAddNumbersCustomCasesAndSavesOutputOfRun(t *testing.T) {
+	resetViperState(t)
+	noSandbox = true
+
+	tmp := t.TempDir()
+	directory := filepath.Join(tmp, "a")
+	if err := os.MkdirAll(filepath.Join(directory, "tests"), 0o755); err != nil {
+		t.Fatalf("failed to create problem dir: %v", err)
+	}
+	for _, file := range []string{"main.cpp", "a.out", "tests/custom-2.in"} {
+		if err := os.WriteFile(filepath.Join(directory, file), []byte("1\n"), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", file, err)
+		}
+	}
+	// a.out is newer than main.cpp, so that it is not compiled.
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(directory, "a.out"), future, future); err != nil {
+		t.Fatalf("failed to touch a.out: %v", err)
+	}
+
+	// /dev/null is a character device like a terminal, so that the input is edited.
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatalf("failed to open %s: %v", os.DevNull, err)
+	}
+	defer devNull.Close()
+	origStdin := os.Stdin
+	defer func() { os.Stdin = origStdin }()
+	os.Stdin = devNull
+
+	t.Setenv("VISUAL", "my-editor --wait")
+	recorder := &shell.Recorder{Handle: func(c *shell.Cmd) error {
+		switch c.Path {
+		case "my-editor":
+			return os.WriteFile(c.Args[len(c.Args)-1], []byte("3 1 2\n"), 0o644)
+		case commandPath(executablePath(directory)):
+			_, err := c.Stdout.Write([]byte("1 2 3\n"))
+			return err
+		}
+		return fmt.Errorf("unexpected command: %s", c)
+	}}
+	runner = recorder
+	caseFromRun = true
+	if err := caseAddCmd.RunE(caseAddCmd, []string{directory}); err != nil {
+		t.Fatalf("case add failed: %v", err)
+	}
+
+	want := []string{
+		"my-editor --wait " + shell.Quote(filepath.Join(directory, "tests", "custom-3.in")),
+		commandPath(executablePath(directory)) + " < " + shell.Quote(filepath.Join(directory, "tests", "custom-3.in")),
+	}
+	if got := recorder.Lines(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("commands mismatch:\nwant: %q\ngot : %q", want, got)
+	}
+	for file, want := range map[string]string{"custom-3.in": "3 1 2\n", "custom-3.out": "1 2 3\n"} {
+		got, err := os.ReadFile(filepath.Join(directory, "tests", file))
+		if err != nil || string(got) != want {
+			t.Fatalf("unexpected %s: %q (%v)", file, got, err)
+		}
+	}
+
+	if err := caseRmCmd.RunE(caseRmCmd, []string{directory, "custom-3", "custom-9"}); err == nil {
+		t.Fatalf("expected rm to fail for an unknown case")
+	}
+	if _, err := os.Stat(filepath.Join(directory, "tests", "custom-3.in")); err != nil {
+		t.Fatalf("expected no case to be removed when one is unknown: %v", err)
+	}
+	if err := caseRmCmd.RunE(caseRmCmd, []string{directory, "tests/custom-3.in"}); err != nil {
+		t.Fatalf("case rm failed: %v", err)
+	}
+	for _, file := range []string{"custom-3.in", "custom-3.out"} {
+		if _, err := os.Stat(filepath.Join(directory, "tests", file)); err == nil {
+			t.Fatalf("expected %s to be removed", file)
+		}
+	}
+}
+
+func TestReleaseFlagsAndBenchStats(t *testing.T) {
+	got := releaseFlags([]string{"-g", "-Wall", "-fsanitize=undefined,address", "-D_GLIBCXX_DEBUG", "-std=c++23", "-I/opt/ac-library"})
+	want := []string{"-Wall", "-std=c++23", "-I/opt/ac-library", "-O2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("release flags mismatch:\nwant: %q\ngot : %q", want, got)
+	}
+	if got := releaseFlags([]string{"-O3", "-g3"}); !reflect.DeepEqual(got, []string{"-O3"}) {
+		t.Fatalf("expected the optimization level to be kept: %q", got)
+	}
+
+	stats := newBenchStats([]time.Duration{30, 10, 20, 50}, 1<<20)
+	if stats.Min != 10 || stats.Median != 25 || stats.Max != 50 || stats.Memory != 1<<20 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestCompareSolutionsAndResolveSource(t *testing.T) {
+	ok := func(stdout string) judge.Result {
+		return judge.Result{Verdict: judge.OK, Stdout: []byte(stdout)}
+	}
+	if got := compareSolutions(ok("1 2\n"), ok("1  2")); got != "same" {
+		t.Fatalf("expected outputs differing in whitespace to be the same, got %q", got)
+	}
+	if got := compareSolutions(ok("1 2\n"), ok("1 3\n")); got != "DIFF" {
+		t.Fatalf("expected DIFF, got %q", got)
+	}
+	if got := compareSolutions(ok("1\n"), judge.Result{Verdict: judge.RE}); got != "OK/RE" {
+		t.Fatalf("expected the verdicts, got %q", got)
+	}
+	if got := formatRatio(20*time.Millisecond, 10*time.Millisecond); got != "0.50x" {
+		t.Fatalf("unexpected ratio %q", got)
+	}
+
+	dir := t.TempDir()
+	alt := filepath.Join(dir, "alt.cpp")
+	if err := os.WriteFile(alt, []byte("int main() {}\n"), 0o644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+	if got, err := resolveSource(dir, "alt.cpp"); err != nil || got != alt {
+		t.Fatalf("expected %s, got %q (%v)", alt, got, err)
+	}
+	if _, err := resolveSource(dir, "missing.cpp"); err == nil {
+		t.Fatal("expected an error for a missing source")
+	}
+}
+
+func TestDebugRunsDebuggerOnCase(t *testing.T) {
+	resetViperState(t)
+	noSandbox = true
+
+	tmp := t.TempDir()
+	directory := filepath.Join(tmp, "a b")
+	if err := os.MkdirAll(filepath.Join(directory, "tests"), 0o755); err != nil {
+		t.Fatalf("failed to create problem dir: %v", err)
+	}
+	for _, file := range []string{"main.cpp", DEBUG_EXECUTABLE, "tests/sample-3.in", "tests/custom-1.in"} {
+		if err := os.WriteFile(filepath.Join(directory, file), []byte("1\n"), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", file, err)
+		}
+	}
+	// debug.out is newer than main.cpp, so that it is not compiled.
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(directory, DEBUG_EXECUTABLE), future, future); err != nil {
+		t.Fatalf("failed to touch %s: %v", DEBUG_EXECUTABLE, err)
+	}
+	bin := filepath.Join(tmp, "bin")
+	if err := os.MkdirAll(bin, 0o755); err != nil {
+		t.Fatalf("failed to create bin: %v", err)
+	}
+	for _, debugger := range []string{"gdb", "lldb"} {
+		if err := os.WriteFile(filepath.Join(bin, debugger), []byte("#!/bin/sh\n"), 0o755); err != nil {
+			t.Fatalf("failed to write %s: %v", debugger, err)
+		}
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	recorder := &shell.Recorder{}
+	runner = recorder
+	debugCase = "3"
+	if err := debugCmd.RunE(debugCmd, []string{directory, "--verbose"}); err != nil {
+		t.Fatalf("debug failed: %v", err)
+	}
+	executable := commandPath(filepath.Join(directory, DEBUG_EXECUTABLE))
+	input := filepath.Join(directory, "tests", "sample-3.in")
+	want := []string{
+		shell.Join([]string{"gdb", "-q", "-ex", "set args --verbose < " + shell.Quote(input), executable}),
+	}
+	if got := recorder.Lines(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("commands mismatch:\nwant: %q\ngot : %q", want, got)
+	}
+
+	got := debuggerArgs("lldb", executable, input, nil, true)
+	wantArgs := []string{"--batch", "-o", `settings set target.input-path "` + input + `"`, "-o", "run", "-k", "bt", "--", executable}
+	if !reflect.DeepEqual(got, wantArgs) {
+		t.Fatalf("lldb arguments mismatch:\nwant: %q\ngot : %q", wantArgs, got)
+	}
+
+	recorder.Handle = func(c *shell.Cmd) error {
+		_, err := c.Stdout.Write([]byte("Reading symbols from debug.out...\n\nProgram received signal SIGSEGV, Segmentation fault.\n0x0000555555555189 in solve () at main.cpp:4\n4\t  a[i] = 1;\n#0  0x0000555555555189 in solve () at main.cpp:4\n#1  0x00005555555551a6 in main () at main.cpp:8\n"))
+		return err
+	}
+	lines, err := backtrace("gdb", executable, judge.Case{Name: "sample-3", Input: input}, nil)
+	if err != nil {
+		t.Fatalf("backtrace failed: %v", err)
+	}
+	wantLines := []string{
+		"Program received signal SIGSEGV, Segmentation fault.",
+		"#0  0x0000555555555189 in solve () at main.cpp:4",
+		"#1  0x00005555555551a6 in main () at main.cpp:8",
+	}
+	if !reflect.DeepEqual(lines, wantLines) {
+		t.Fatalf("backtrace mismatch:\nwant: %q\ngot : %q", wantLines, lines)
+	}
+
+	if _, err := findCase(directory, "1"); err != nil {
+		t.Fatalf("expected 1 to find custom-1: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(directory, "tests", "sample-1.in"), nil, 0o644); err != nil {
+		t.Fatalf("failed to write sample-1.in: %v", err)
+	}
+	if _, err := findCase(directory, "1"); err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Fatalf("expected 1 to be ambiguous, got %v", err)
+	}
+}
+
+func TestCommandsRecordHistory(t *testing.T) {
+	resetViperState(t)
+
+	tmp := t.TempDir()
+	t.Setenv("HOME", tmp)
+	contest := filepath.Join(tmp, "abc300")
+	templatePath = filepath.Join(tmp, "template.cpp")
+	if err := os.WriteFile(templatePath, []byte("int main() {}\n"), 0o644); err != nil {
+		t.Fatalf("failed to write template: %v", err)
+	}
+	if err := os.MkdirAll(contest, 0o755); err != nil {
+		t.Fatalf("failed to create contest dir: %v", err)
+	}
+
+	directory := filepath.Join(contest, "a")
+	if err := newCmd.RunE(newCmd, []string{directory}); err != nil {
+		t.Fatalf("new failed: %v", err)
+	}
+	if err := submittedCmd.RunE(submittedCmd, []string{directory, "wa"}); err != nil {
+		t.Fatalf("submitted failed: %v", err)
+	}
+	if err := submittedCmd.RunE(submittedCmd, []string{directory, "WJ"}); err == nil {
+		t.Fatal("expected an unknown verdict to be rejected")
+	}
+	recordEvent(directory, testEvent(judge.Summary{judge.AC: 2, judge.WA: 1}))
+	// Nothing is copied without a clipboard command, so it is not recorded.
+	t.Setenv("PATH", filepath.Join(tmp, "empty-bin"))
+	origStdout := os.Stdout
+	os.Stdout, _ = os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	err := clipCmd.RunE(clipCmd, []string{directory})
+	os.Stdout.Close()
+	os.Stdout = origStdout
+	if err != nil {
+		t.Fatalf("clip failed: %v", err)
+	}
+
+	events, err := history.Load(contest)
+	if err != nil {
+		t.Fatalf("failed to load the history: %v", err)
+	}
+	var got []string
+	for _, event := range events {
+		got = append(got, fmt.Sprintf("%s %s %s %v", event.Kind, event.Problem, event.Verdict, event.Verdicts))
+	}
+	want := []string{"new a  map[]", "submit a WA map[]", "test a WA map[AC:2 WA:1]"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("history mismatch:\nwant: %q\ngot : %q", want, got)
+	}
+
+	viper.Set(HISTORY_KEY, false)
+	recordEvent(directory, history.Event{Kind: history.EVENT_CLIP})
+	if events, _ := history.Load(contest); len(events) != 3 {
+		t.Fatalf("expected no event to be recorded with HISTORY = false, got %d events", len(events))
+	}
+}
+
+func TestUnknownJudgePresetFailsCommand(t *testing.T) {
+	resetViperState(t)
+	viper.Set(JUDGE_PRESET_KEY, "atcoder-gc")
+	recorder := &shell.Recorder{}
+	runner = recorder
+
+	err := rootCmd.PersistentPreRunE(runCmd, []string{"a"})
+	if err == nil || !strings.Contains(err.Error(), `unknown judge preset "atcoder-gc"`) {
+		t.Fatalf("expected run to fail with the unknown preset, got %v", err)
+	}
+	if got := recorder.Lines(); len(got) != 0 {
+		t.Fatalf("expected nothing to be run: %q", got)
+	}
+	// The config commands can still fix it.
+	if err := rootCmd.PersistentPreRunE(configSetCmd, []string{JUDGE_PRESET_KEY, "atcoder-gcc"}); err != nil {
+		t.Fatalf("expected config set to run with the unknown preset, got %v", err)
+	}
+}
+
+func TestCompileKeepsRawOutputInLog(t *testing.T) {
+	resetViperState(t)
+	viper.Set(CXX_KEY, "fake-g++")
+	jsonDiagnosticsSupport["fake-g++"] = true
+	defer delete(jsonDiagnosticsSupport, "fake-g++")
+
+	directory := t.TempDir()
+	output := `[{"kind": "warning", "children": [], "locations": [{"caret": {"line": 3, "file": "main.cpp", "column": 7}}], "message": "unused variable 'y'"}]
+/usr/bin/ld: cannot find -lgmp: No such file or directory
+collect2: error: ld returned 1 exit status
+`
+	runner = &shell.Recorder{Handle: func(c *shell.Cmd) error {
+		_, _ = c.Stderr.Write([]byte(output))
+		return errors.New("exit status 1")
+	}}
+	if err := compile(sourcePath(directory), executablePath(directory)); err == nil {
+		t.Fatal("expected the compilation to fail")
+	}
+
+	log, err := os.ReadFile(filepath.Join(directory, COMPILE_LOG_FILE))
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", COMPILE_LOG_FILE, err)
+	}
+	if string(log) != output {
+		t.Fatalf("expected the full output in %s:\nwant: %q\ngot : %q", COMPILE_LOG_FILE, output, log)
+	}
+}
+
+func TestNewCmdRendersOnlyTmplTemplates(t *testing.T) {
+	resetViperState(t)
+
+	tmp := t.TempDir()
+	t.Setenv("HOME", tmp)
+	origWD, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get wd: %v", err)
+	}
+	defer func() {
+		_ = os.Chdir(origWD)
+	}()
+	if err := os.Chdir(tmp); err != nil {
+		t.Fatalf("failed to chdir: %v", err)
+	}
+
+	braces := "#include <bits/stdc++.h>\nusing namespace std;\nvector<pair<int,int>> d = {{0,1},{1,0}};\n"
+	templatePath = filepath.Join(tmp, "template.cpp")
+	if err := os.WriteFile(templatePath, []byte(braces), 0o644); err != nil {
+		t.Fatalf("failed to write template: %v", err)
+	}
+	if err := newCmd.RunE(newCmd, []string{"a"}); err != nil {
+		t.Fatalf("new failed with a brace initializer: %v", err)
+	}
+	if content, err := os.ReadFile(filepath.Join(tmp, "a", "main.cpp")); err != nil || string(content) != braces {
+		t.Fatalf("expected the template to be copied as it is, got %q (%v)", content, err)
+	}
+
+	templatePath = filepath.Join(tmp, "template.cpp.tmpl")
+	if err := os.WriteFile(templatePath, []byte("// {{.Problem}}\n"), 0o644); err != nil {
+		t.Fatalf("failed to write template: %v", err)
+	}
+	if err := newCmd.RunE(newCmd, []string{"b"}); err != nil {
+		t.Fatalf("new failed: %v", err)
+	}
+	if content, err := os.ReadFile(filepath.Join(tmp, "b", "main.cpp")); err != nil || string(content) != "// b\n" {
+		t.Fatalf("expected the .tmpl template to be rendered, got %q (%v)", content, err)
+	}
+}
+
+func TestDoctorReportsMissingLinkLibraryApartFromSanitizers(t *testing.T) {
+	resetViperState(t)
+	tmp := t.TempDir()
+	t.Setenv("HOME", tmp)
+
+	// The fake compiler fails to link with -lgmp, and otherwise writes an executable which succeeds.
+	cxx := filepath.Join(tmp, "g++-12")
+	script := `#!/bin/sh
+case "$*" in
+*--version*) echo "g++ (GCC) 12.2.0"; exit 0 ;;
+*-lgmp*) printf '/usr/bin/ld: cannot find -lgmp: No such file or directory\ncollect2: error: ld returned 1 exit status\n' >&2; exit 1 ;;
+esac
+while [ $# -gt 0 ]; do
+	if [ "$1" = -o ]; then printf '#!/bin/sh\n' > "$2"; chmod +x "$2"; fi
+	shift
+done
+`
+	if err := os.WriteFile(cxx, []byte(script), 0o755); err != nil {
+		t.Fatalf("failed to write the fake compiler: %v", err)
+	}
+	viper.Set(CXX_KEY, cxx)
+	viper.Set(JUDGE_PRESET_KEY, "atcoder-gcc")
+	if err := resolveJudgePreset(); err != nil {
+		t.Fatalf("failed to resolve the preset: %v", err)
+	}
+
+	checks := map[string]DoctorCheck{}
+	for _, check := range runDoctorChecks() {
+		checks[check.Name] = check
+	}
+	if checks["sanitizers"].Status != DOCTOR_PASS {
+		t.Fatalf("expected the sanitizers to pass without the link flags: %+v", checks["sanitizers"])
+	}
+	if link := checks["link libraries"]; link.Status != DOCTOR_FAIL || !strings.Contains(link.Detail, "cannot find -lgmp") {
+		t.Fatalf("expected the missing library to be reported: %+v", link)
+	}
+}
+
+func TestCXXWithArguments(t *testing.T) {
+	resetViperState(t)
+	viper.Set(CXX_KEY, "ccache g++-13 -fdiagnostics-color")
+	jsonDiagnosticsSupport[GetCXX()] = false
+	defer delete(jsonDiagnosticsSupport, GetCXX())
+
+	recorder := &shell.Recorder{}
+	runner = recorder
+	directory := t.TempDir()
+	if err := compileWithFlags(sourcePath(directory), executablePath(directory), []string{"-O2"}); err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	want := []string{shell.Join([]string{"ccache", "g++-13", "-fdiagnostics-color", sourcePath(directory), "-O2", "-o", executablePath(directory)})}
+	if got := recorder.Lines(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("commands mismatch:\nwant: %q\ngot : %q", want, got)
+	}
+
+	// The compiler itself is looked up, not the whole CXX.
+	bin := t.TempDir()
+	script := "#!/bin/sh\n[ \"$1\" = -v2 ] && [ \"$2\" = --version ] && echo 'g++ (GCC) 13.2.0'\n"
+	if err := os.WriteFile(filepath.Join(bin, "fake-g++"), []byte(script), 0o755); err != nil {
+		t.Fatalf("failed to write the fake compiler: %v", err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	version, err := GetCompilerVersion("fake-g++ -v2")
+	if err != nil || version.Major != 13 {
+		t.Fatalf("expected version 13, got %+v (%v)", version, err)
+	}
+	if check := checkCompiler("fake-g++ -v2"); check.Status != DOCTOR_PASS {
+		t.Fatalf("expected the compiler check to pass: %+v", check)
+	}
+}
+
+func TestSplitCompilesReferenceOnlyForFailedCases(t *testing.T) {
+	resetViperState(t)
+	viper.Set(CXX_KEY, "acutils-no-such-compiler")
+	directory := filepath.Join(t.TempDir(), "a")
+	if err := os.MkdirAll(directory, 0o755); err != nil {
+		t.Fatalf("failed to create problem dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(directory, NAIVE_SOURCE_FILE), []byte("int main() {}\n"), 0o644); err != nil {
+		t.Fatalf("failed to write naive.cpp: %v", err)
+	}
+	recorder := &shell.Recorder{}
+	runner = recorder
+	splitter, err := judge.ParseSplitter("lines:1")
+	if err != nil {
+		t.Fatalf("failed to parse the splitter: %v", err)
+	}
+
+	passed := []judge.Result{{Case: judge.Case{Name: "1"}, Verdict: judge.AC}}
+	diagnoseSubcases(directory, passed, splitter)
+	if got := recorder.Lines(); len(got) != 0 {
+		t.Fatalf("expected the reference solution not to be compiled without failed cases: %q", got)
+	}
+
+	failed := []judge.Result{{Case: judge.Case{Name: "1", Input: filepath.Join(directory, "1.in")}, Verdict: judge.WA}}
+	diagnoseSubcases(directory, failed, splitter)
+	if got := recorder.Lines(); len(got) != 1 || !strings.Contains(got[0], NAIVE_SOURCE_FILE) {
+		t.Fatalf("expected the reference solution to be compiled for the failed case: %q", got)
+	}
+}
+
+func TestTextDiagnosesAreRejectedWithNonTextFormat(t *testing.T) {
+	for flag, set := range map[string]func(){
+		"--split":     func() { testSplit = "lines:1" },
+		"--backtrace": func() { testBacktrace = true },
+	} {
+		resetViperState(t)
+		testFormat = string(judge.FORMAT_JUNIT)
+		set()
+		recorder := &shell.Recorder{}
+		runner = recorder
+
+		err := testCmd.RunE(testCmd, []string{filepath.Join(t.TempDir(), "a")})
+		if err == nil || !strings.Contains(err.Error(), flag) {
+			t.Fatalf("expected %s to be rejected with --format junit, got %v", flag, err)
+		}
+		if got := recorder.Lines(); len(got) != 0 {
+			t.Fatalf("expected nothing to be run with %s: %q", flag, got)
+		}
+	}
+}
+
+func TestTestAndWatchFromInsideProblemDirectory(t *testing.T) {
+	resetViperState(t)
+	t.Setenv("HOME", t.TempDir())
+	directory := filepath.Join(t.TempDir(), "a")
+	if err := os.MkdirAll(filepath.Join(directory, "tests"), 0o755); err != nil {
+		t.Fatalf("failed to create problem dir: %v", err)
+	}
+	files := map[string]string{"main.cpp": "int main() {}\n", "tests/1.in": "3\n", "tests/1.out": "3\n"}
+	for file, content := range files {
+		if err := os.WriteFile(filepath.Join(directory, file), []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", file, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(directory, "a.out"), []byte("#!/bin/sh\ncat\n"), 0o755); err != nil {
+		t.Fatalf("failed to write a.out: %v", err)
+	}
+	// a.out is newer than main.cpp, so that it is not compiled.
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(directory, "a.out"), future, future); err != nil {
+		t.Fatalf("failed to touch a.out: %v", err)
+	}
+
+	origWD, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get wd: %v", err)
+	}
+	defer func() {
+		_ = os.Chdir(origWD)
+	}()
+	if err := os.Chdir(directory); err != nil {
+		t.Fatalf("failed to chdir: %v", err)
+	}
+
+	origStdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to pipe stdout: %v", err)
+	}
+	os.Stdout = w
+	defer func() {
+		_ = w.Close()
+		os.Stdout = origStdout
+	}()
+
+	// a.out is not looked up in $PATH.
+	testErr := testCmd.RunE(testCmd, []string{"."})
+	watchIteration(".", false)
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to close writer: %v", err)
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("failed to read stdout: %v", err)
+	}
+	if testErr != nil {
+		t.Fatalf("test . failed: %v\n%s", testErr, out)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(out)), "\n"); !strings.Contains(lines[len(lines)-1], "AC 1/1") {
+		t.Fatalf("expected watch . to pass the case:\n%s", out)
+	}
+}
+
+func TestConfigEditDoesNotCreateFileInDryRun(t *testing.T) {
+	resetViperState(t)
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("VISUAL", "my-editor --wait")
+	dryRun = true
+	recorder := &shell.Recorder{}
+	runner = recorder
+
+	if err := configEditCmd.RunE(configEditCmd, nil); err != nil {
+		t.Fatalf("config edit failed: %v", err)
+	}
+	path := filepath.Join(home, ".acutils-cli", "config.toml")
+	if want := []string{"my-editor --wait " + shell.Quote(path)}; !reflect.DeepEqual(recorder.Lines(), want) {
+		t.Fatalf("commands mismatch:\nwant: %q\ngot : %q", want, recorder.Lines())
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected the config file not to be created in dry-run: %v", err)
+	}
+}
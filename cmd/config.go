@@ -0,0 +1,423 @@
+/*
+Copyright © 2024 lemolatoon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/lemolatoon/acutils-cli/sandbox"
+	"github.com/lemolatoon/acutils-cli/shell"
+	"github.com/lemolatoon/acutils-cli/tomledit"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// ConfigType is the type of the value of a config key.
+type ConfigType string
+
+const (
+	CONFIG_STRING   ConfigType = "string"
+	CONFIG_BOOL     ConfigType = "bool"
+	CONFIG_STRINGS  ConfigType = "strings"
+	CONFIG_DURATION ConfigType = "duration"
+	CONFIG_SIZE     ConfigType = "size"
+	CONFIG_INT      ConfigType = "integer"
+	CONFIG_TABLE    ConfigType = "table"
+	CONFIG_TABLES   ConfigType = "array of tables"
+)
+
+// ConfigKey describes a key of config.toml.
+type ConfigKey struct {
+	Name        string
+	Type        ConfigType
+	Description string
+	// Flag is the name of the global flag bound to the key, if any.
+	Flag string
+	// Path is true for paths, which are relative to the config file declaring them.
+	Path bool
+	// Validate checks the value beyond its type, e.g. that a preset exists.
+	Validate func(value any) error
+	// Effective returns the value in effect, including the built-in default.
+	// If nil, the value is read from viper as it is.
+	Effective func() any
+}
+
+// CONFIG_KEYS are all the keys known to acutils-cli.
+var CONFIG_KEYS = []ConfigKey{
+	{Name: CXX_KEY, Type: CONFIG_STRING, Description: "compiler command, which may have arguments (e.g. \"ccache g++\")", Effective: func() any { return GetCXX() }},
+	{Name: CXXFLAGS_KEY, Type: CONFIG_STRINGS, Description: "compiler flags", Effective: func() any { return GetCXXFLAGS() }},
+	{Name: JUDGE_PRESET_KEY, Type: CONFIG_STRING, Description: "judge preset to replicate the compiler environment of", Flag: "judge", Validate: validateJudgePreset},
+	{Name: INCLUDE_PATHS_KEY, Type: CONFIG_STRINGS, Description: "library include paths", Path: true, Effective: func() any { return GetIncludePaths() }},
+	{Name: TIME_LIMIT_KEY, Type: CONFIG_DURATION, Description: "time limit to judge TLE", Effective: func() any { return GetTimeLimit() }},
+	{Name: BENCH_WARN_PERCENT_KEY, Type: CONFIG_INT, Description: "percentage of the time limit over which bench warns", Effective: func() any { return GetBenchWarnPercent() }},
+	{Name: DEBUGGER_KEY, Type: CONFIG_STRING, Description: "gdb or lldb command used by debug", Validate: validateDebugger, Effective: func() any { return GetDebugger() }},
+	{Name: HISTORY_KEY, Type: CONFIG_BOOL, Description: "record the events of the problems in the contest directory for stats", Effective: func() any { return IsHistoryEnabled() }},
+	{Name: SANDBOX_KEY, Type: CONFIG_BOOL, Description: "run solutions with the resource limits (--no-sandbox disables it)", Effective: func() any { return IsSandboxed() }},
+	{Name: MEMORY_LIMIT_KEY, Type: CONFIG_SIZE, Description: "address space limit of solutions, ignored with AddressSanitizer", Effective: func() any { return sandbox.FormatSize(GetMemoryLimit()) }},
+	{Name: FILE_SIZE_LIMIT_KEY, Type: CONFIG_SIZE, Description: "size limit of each file written by solutions", Effective: func() any { return sandbox.FormatSize(GetFileSizeLimit()) }},
+	{Name: PROCESS_LIMIT_KEY, Type: CONFIG_INT, Description: "number of processes and threads solutions may create", Effective: func() any { return GetProcessLimit() }},
+	{Name: SANDBOX_TEMP_DIR_KEY, Type: CONFIG_BOOL, Description: "run solutions in a fresh temporary working directory", Effective: func() any { return viper.GetBool(SANDBOX_TEMP_DIR_KEY) }},
+	{Name: TEMPLATE_FILE_KEY, Type: CONFIG_STRING, Description: "template file or directory for new problems", Path: true},
+	{Name: TEMPLATE_RULES_KEY, Type: CONFIG_TABLES, Description: "templates selected by contest/problem patterns", Validate: validateTemplateRules},
+	{Name: TEMPLATE_VARS_KEY, Type: CONFIG_TABLE, Description: "custom values for templates ({{.Vars.name}})"},
+	{Name: AUTHOR_KEY, Type: CONFIG_STRING, Description: "author for templates ({{.Author}})"},
+	{Name: VSCODE_TEMPLATE_SETTINGS_FILE_KEY, Type: CONFIG_STRING, Description: ".vscode/settings.json for init", Path: true},
+	{Name: STRICT_KEY, Type: CONFIG_BOOL, Description: "fail instead of falling back when template/settings files are missing", Flag: "strict", Effective: func() any { return IsStrict() }},
+}
+
+// lookupConfigKey finds the key ignoring case, as viper does.
+func lookupConfigKey(name string) (ConfigKey, bool) {
+	for _, key := range CONFIG_KEYS {
+		if strings.EqualFold(key.Name, name) {
+			return key, true
+		}
+	}
+
+	return ConfigKey{}, false
+}
+
+func configKeyNames() []string {
+	names := make([]string, len(CONFIG_KEYS))
+	for i, key := range CONFIG_KEYS {
+		names[i] = key.Name
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func unknownConfigKeyError(name string) error {
+	if suggestion := suggest(name, configKeyNames()); suggestion != "" {
+		return fmt.Errorf("unknown config key %q (did you mean %s?)", name, suggestion)
+	}
+
+	return fmt.Errorf("unknown config key %q (known keys: %s)", name, strings.Join(configKeyNames(), ", "))
+}
+
+// configOrigin returns where the value of the key comes from: a flag, an env var,
+// the config file or the default.
+func configOrigin(key ConfigKey) string {
+	if key.Flag != "" {
+		if flag := rootCmd.PersistentFlags().Lookup(key.Flag); flag != nil && flag.Changed {
+			return "flag --" + key.Flag
+		}
+	}
+	if value, ok := os.LookupEnv(key.Name); ok && value != "" {
+		return "env " + key.Name
+	}
+	if projectConfigKeys[strings.ToLower(key.Name)] {
+		return "project config"
+	}
+	if viper.InConfig(key.Name) {
+		return "config file"
+	}
+
+	return "default"
+}
+
+func configValue(key ConfigKey) any {
+	if key.Effective != nil {
+		return key.Effective()
+	}
+	return viper.Get(key.Name)
+}
+
+func formatConfigValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []string:
+		return strings.Join(v, " ")
+	case []any:
+		if len(v) == 0 {
+			return ""
+		}
+		return fmt.Sprintf("%d entries", len(v))
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		pairs := make([]string, len(keys))
+		for i, key := range keys {
+			pairs[i] = fmt.Sprintf("%s=%v", key, v[key])
+		}
+		return strings.Join(pairs, " ")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// configFilePath returns the config file used by initConfig, or the default one
+// which is created by `config set` if it does not exist.
+func configFilePath() (string, error) {
+	if cfgFile != "" {
+		return cfgFile, nil
+	}
+	if used := viper.ConfigFileUsed(); used != "" {
+		return used, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, ".acutils-cli", "config.toml"), nil
+}
+
+// tomlValue encodes the arguments of `config set` as the TOML value for the key.
+func tomlValue(key ConfigKey, args []string) (string, error) {
+	switch key.Type {
+	case CONFIG_STRING:
+		if len(args) != 1 {
+			return "", fmt.Errorf("%s takes exactly one value", key.Name)
+		}
+		return tomledit.String(args[0]), nil
+	case CONFIG_STRINGS:
+		return tomledit.Array(args), nil
+	case CONFIG_BOOL:
+		if len(args) != 1 {
+			return "", fmt.Errorf("%s takes exactly one value", key.Name)
+		}
+		value, err := strconv.ParseBool(args[0])
+		if err != nil {
+			return "", fmt.Errorf("invalid bool for %s (true or false): %w", key.Name, err)
+		}
+		return strconv.FormatBool(value), nil
+	case CONFIG_DURATION:
+		if len(args) != 1 {
+			return "", fmt.Errorf("%s takes exactly one value", key.Name)
+		}
+		if _, err := time.ParseDuration(args[0]); err != nil {
+			return "", fmt.Errorf("invalid duration for %s (e.g. 2s, 500ms): %w", key.Name, err)
+		}
+		return tomledit.String(args[0]), nil
+	case CONFIG_SIZE:
+		if len(args) != 1 {
+			return "", fmt.Errorf("%s takes exactly one value", key.Name)
+		}
+		if _, err := sandbox.ParseSize(args[0]); err != nil {
+			return "", fmt.Errorf("%s: %w", key.Name, err)
+		}
+		return tomledit.String(args[0]), nil
+	case CONFIG_INT:
+		if len(args) != 1 {
+			return "", fmt.Errorf("%s takes exactly one value", key.Name)
+		}
+		value, err := strconv.Atoi(args[0])
+		if err != nil || value <= 0 {
+			return "", fmt.Errorf("invalid positive integer for %s: %q", key.Name, args[0])
+		}
+		return strconv.Itoa(value), nil
+	default:
+		return "", fmt.Errorf("%s is a %s; use `acutils-cli config edit` to change it", key.Name, key.Type)
+	}
+}
+
+// editConfigFile applies edit to the content of the config file, creating the file if needed.
+func editConfigFile(edit func(content string) (string, error)) (string, error) {
+	path, err := configFilePath()
+	if err != nil {
+		return "", err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	edited, err := edit(string(content))
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+
+	return path, os.WriteFile(path, []byte(edited), 0644)
+}
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Show and edit the configuration.",
+	Long: `Show and edit the configuration.
+
+The configuration is read from config.toml (default: $HOME/.acutils-cli/config.toml),
+the project config .acutils.toml found by walking up from the working directory,
+env vars with the same names as the keys and global flags, in the order of priority.`,
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all known keys with their effective values and origins.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return printConfigList(os.Stdout)
+	},
+}
+
+func printConfigList(out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tORIGIN\tDESCRIPTION")
+	for _, key := range CONFIG_KEYS {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", key.Name, formatConfigValue(configValue(key)), configOrigin(key), key.Description)
+	}
+
+	return w.Flush()
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get KEY",
+	Short: "Print the effective value of the key.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key, ok := lookupConfigKey(args[0])
+		if !ok {
+			return unknownConfigKeyError(args[0])
+		}
+		fmt.Println(formatConfigValue(configValue(key)))
+		return nil
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set KEY VALUE...",
+	Short: "Set the key in the config file, keeping the rest of the file as it is.",
+	Long: `Set the key in the config file, keeping the rest of the file as it is.
+
+Keys taking a list (e.g. CXXFLAGS) take multiple values:
+
+  acutils-cli config set CXXFLAGS -- -O2 -std=c++23`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key, ok := lookupConfigKey(args[0])
+		if !ok {
+			return unknownConfigKeyError(args[0])
+		}
+		value, err := tomlValue(key, args[1:])
+		if err != nil {
+			return err
+		}
+		path, err := editConfigFile(func(content string) (string, error) {
+			return tomledit.Set(content, key.Name, value), nil
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("set %s = %s in %s\n", key.Name, value, path)
+		return nil
+	},
+}
+
+var configUnsetCmd = &cobra.Command{
+	Use:   "unset KEY",
+	Short: "Remove the key from the config file.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key, ok := lookupConfigKey(args[0])
+		if !ok {
+			return unknownConfigKeyError(args[0])
+		}
+		path, err := editConfigFile(func(content string) (string, error) {
+			edited, ok := tomledit.Unset(content, key.Name)
+			if !ok {
+				return "", fmt.Errorf("%s is not set in the config file", key.Name)
+			}
+			return edited, nil
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("unset %s in %s\n", key.Name, path)
+		return nil
+	},
+}
+
+var configEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Open the config file with $VISUAL or $EDITOR.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := configFilePath()
+		if err != nil {
+			return err
+		}
+		// The file is created for the editor, except in dry-run, which only prints the editor command.
+		if !dryRun {
+			if _, err := editConfigFile(func(content string) (string, error) {
+				return content, nil
+			}); err != nil {
+				return err
+			}
+		}
+		editor := editorCommand()
+		return runner.Run(shell.Command(editor[0], append(editor[1:], path)...))
+	},
+}
+
+// editorCommand returns the argv of $VISUAL or $EDITOR (e.g. "code --wait"), falling back to vi.
+func editorCommand() []string {
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if editor := strings.Fields(os.Getenv(name)); len(editor) != 0 {
+			return editor
+		}
+	}
+
+	return []string{"vi"}
+}
+
+var configPathCmd = &cobra.Command{
+	Use:   "path",
+	Short: "Print the paths of the config file and the project config file.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := configFilePath()
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(path); err != nil {
+			fmt.Printf("%s (not created yet)\n", path)
+		} else {
+			fmt.Println(path)
+		}
+		if projectConfigFile != "" {
+			fmt.Printf("%s (project config)\n", projectConfigFile)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configListCmd, configGetCmd, configSetCmd, configUnsetCmd, configEditCmd, configPathCmd, configValidateCmd)
+}
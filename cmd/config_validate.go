@@ -0,0 +1,442 @@
+/*
+Copyright © 2024 lemolatoon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lemolatoon/acutils-cli/sandbox"
+	"github.com/lemolatoon/acutils-cli/tomledit"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// ConfigProblem is a problem found in a config file.
+type ConfigProblem struct {
+	File string
+	// Line is 1-based, or 0 if unknown.
+	Line    int
+	Warning bool
+	Message string
+}
+
+func (p ConfigProblem) String() string {
+	location := p.File
+	if p.Line > 0 {
+		location = fmt.Sprintf("%s:%d", p.File, p.Line)
+	}
+	severity := "error"
+	if p.Warning {
+		severity = "warning"
+	}
+
+	return fmt.Sprintf("%s: %s: %s", location, severity, p.Message)
+}
+
+// validateConfigFile checks the config file against CONFIG_KEYS: unknown keys, the types
+// of the values and, if checkFiles is true, that the files in the values exist.
+func validateConfigFile(configPath string, checkFiles bool) ([]ConfigProblem, error) {
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var settings map[string]any
+	if err := toml.Unmarshal(content, &settings); err != nil {
+		var decodeErr *toml.DecodeError
+		if errors.As(err, &decodeErr) {
+			line, _ := decodeErr.Position()
+			return []ConfigProblem{{File: configPath, Line: line, Message: decodeErr.Error()}}, nil
+		}
+		return []ConfigProblem{{File: configPath, Message: err.Error()}}, nil
+	}
+
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var problems []ConfigProblem
+	for _, name := range names {
+		problem := ConfigProblem{File: configPath, Line: configKeyLine(string(content), name)}
+		key, ok := lookupConfigKey(name)
+		if !ok {
+			problem.Warning = true
+			problem.Message = fmt.Sprintf("unknown key %q", name)
+			if suggestion := suggest(name, configKeyNames()); suggestion != "" {
+				problem.Message += fmt.Sprintf(" (did you mean %s?)", suggestion)
+			}
+			problems = append(problems, problem)
+			continue
+		}
+
+		value := settings[name]
+		err := validateConfigType(key, value)
+		if err == nil && key.Validate != nil {
+			err = key.Validate(value)
+		}
+		if err == nil && checkFiles {
+			err = checkConfigFiles(key, value, filepath.Dir(configPath))
+		}
+		if err != nil {
+			problem.Message = fmt.Sprintf("%s: %v", key.Name, err)
+			problems = append(problems, problem)
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Line < problems[j].Line
+	})
+
+	return problems, nil
+}
+
+// configKeyLine returns the line of the top-level key or of the table with the name.
+func configKeyLine(content string, name string) int {
+	if line, ok := tomledit.Line(content, name); ok {
+		return line
+	}
+	for i, line := range strings.Split(content, "\n") {
+		header := strings.Trim(strings.TrimSpace(line), "[] \t")
+		if strings.HasPrefix(strings.TrimSpace(line), "[") && strings.EqualFold(header, name) {
+			return i + 1
+		}
+	}
+
+	return 0
+}
+
+func validateConfigType(key ConfigKey, value any) error {
+	switch key.Type {
+	case CONFIG_STRING:
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("must be a string, got %s", tomlTypeName(value))
+		}
+	case CONFIG_BOOL:
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("must be true or false, got %s", tomlTypeName(value))
+		}
+	case CONFIG_STRINGS:
+		if _, ok := value.(string); ok {
+			// viper splits a string by whitespace.
+			return nil
+		}
+		values, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf(`must be an array of strings (e.g. ["-O2"]), got %s`, tomlTypeName(value))
+		}
+		for i, v := range values {
+			if _, ok := v.(string); !ok {
+				return fmt.Errorf("element %d must be a string, got %s", i+1, tomlTypeName(v))
+			}
+		}
+	case CONFIG_DURATION:
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf(`must be a duration string (e.g. "2s", "500ms"), got %s`, tomlTypeName(value))
+		}
+		duration, err := time.ParseDuration(s)
+		if err != nil || duration <= 0 {
+			return fmt.Errorf(`invalid duration %q (e.g. "2s", "500ms")`, s)
+		}
+	case CONFIG_SIZE:
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf(`must be a size string (e.g. "1024MB", "1GiB"), got %s`, tomlTypeName(value))
+		}
+		if _, err := sandbox.ParseSize(s); err != nil {
+			return err
+		}
+	case CONFIG_INT:
+		n, ok := value.(int64)
+		if !ok {
+			return fmt.Errorf("must be an integer, got %s", tomlTypeName(value))
+		}
+		if n <= 0 {
+			return fmt.Errorf("must be positive, got %d", n)
+		}
+	case CONFIG_TABLE:
+		if _, ok := value.(map[string]any); !ok {
+			return fmt.Errorf("must be a table ([%s]), got %s", key.Name, tomlTypeName(value))
+		}
+	case CONFIG_TABLES:
+		tables, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("must be an array of tables ([[%s]]), got %s", key.Name, tomlTypeName(value))
+		}
+		for i, table := range tables {
+			if _, ok := table.(map[string]any); !ok {
+				return fmt.Errorf("element %d must be a table, got %s", i+1, tomlTypeName(table))
+			}
+		}
+	}
+
+	return nil
+}
+
+func tomlTypeName(value any) string {
+	switch value.(type) {
+	case string:
+		return "a string"
+	case bool:
+		return "a bool"
+	case int64:
+		return "an integer"
+	case float64:
+		return "a float"
+	case []any:
+		return "an array"
+	case map[string]any:
+		return "a table"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func validateJudgePreset(value any) error {
+	name := value.(string)
+	if name == "" {
+		return nil
+	}
+	for _, preset := range JUDGE_PRESETS {
+		if preset.Name == name {
+			return nil
+		}
+	}
+	if suggestion := suggest(name, judgePresetNames()); suggestion != "" {
+		return fmt.Errorf("unknown preset %q (did you mean %s?)", name, suggestion)
+	}
+
+	return fmt.Errorf("unknown preset %q (available: %s)", name, strings.Join(judgePresetNames(), ", "))
+}
+
+func validateDebugger(value any) error {
+	debugger := value.(string)
+	if debugger == "" || isLLDB(debugger) || strings.Contains(filepath.Base(debugger), "gdb") {
+		return nil
+	}
+
+	return fmt.Errorf("unknown debugger %q (gdb or lldb is supported)", debugger)
+}
+
+var templateRuleFields = []string{"pattern", "template", "template_set"}
+
+func validateTemplateRules(value any) error {
+	for i, table := range value.([]any) {
+		rule := table.(map[string]any)
+		fields := map[string]string{}
+		for field, v := range rule {
+			known := suggest(field, templateRuleFields)
+			if !strings.EqualFold(known, field) {
+				if known != "" {
+					return fmt.Errorf("rule %d: unknown field %q (did you mean %s?)", i+1, field, known)
+				}
+				return fmt.Errorf("rule %d: unknown field %q (known fields: %s)", i+1, field, strings.Join(templateRuleFields, ", "))
+			}
+			s, ok := v.(string)
+			if !ok {
+				return fmt.Errorf("rule %d: %s must be a string, got %s", i+1, field, tomlTypeName(v))
+			}
+			fields[strings.ToLower(field)] = s
+		}
+
+		if fields["pattern"] == "" {
+			return fmt.Errorf("rule %d: pattern is missing", i+1)
+		}
+		if _, err := path.Match(strings.ToLower(fields["pattern"]), ""); err != nil {
+			return fmt.Errorf("rule %d: invalid pattern %q: %w", i+1, fields["pattern"], err)
+		}
+		if (fields["template"] == "") == (fields["template_set"] == "") {
+			return fmt.Errorf("rule %d: set either template or template_set", i+1)
+		}
+	}
+
+	return nil
+}
+
+// checkConfigFiles checks that the files in the value exist. Relative paths are resolved
+// against the directory of the config file.
+func checkConfigFiles(key ConfigKey, value any, dir string) error {
+	resolve := func(p string) string {
+		if filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+
+	if key.Path {
+		var paths []string
+		switch v := value.(type) {
+		case string:
+			paths = []string{v}
+		case []any:
+			for _, p := range v {
+				paths = append(paths, p.(string))
+			}
+		}
+		for _, p := range paths {
+			if p == "" {
+				continue
+			}
+			if _, err := os.Stat(resolve(p)); err != nil {
+				return fmt.Errorf("%s does not exist", resolve(p))
+			}
+		}
+	}
+
+	if key.Name == TEMPLATE_RULES_KEY {
+		for i, table := range value.([]any) {
+			for field, v := range table.(map[string]any) {
+				switch strings.ToLower(field) {
+				case "template":
+					if _, err := os.Stat(resolve(v.(string))); err != nil {
+						return fmt.Errorf("rule %d: template %s does not exist", i+1, resolve(v.(string)))
+					}
+				case "template_set":
+					setDir := filepath.Join(templateSetsDir(), v.(string))
+					if info, err := os.Stat(setDir); err != nil || !info.IsDir() {
+						return fmt.Errorf("rule %d: template set %s does not exist", i+1, setDir)
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// suggest returns the candidate closest to the name, ignoring case, if it is close enough
+// to be a typo.
+func suggest(name string, candidates []string) string {
+	best, bestDistance := "", -1
+	for _, candidate := range candidates {
+		distance := levenshtein(strings.ToLower(name), strings.ToLower(candidate))
+		if bestDistance < 0 || distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+	if bestDistance < 0 || bestDistance > max(1, len(name)/3) {
+		return ""
+	}
+
+	return best
+}
+
+func levenshtein(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
+
+// loadedConfigFiles returns config.toml and the project config read by initConfig.
+func loadedConfigFiles() []string {
+	var paths []string
+	if configPath := viper.ConfigFileUsed(); configPath != "" {
+		if _, err := os.Stat(configPath); err == nil {
+			paths = append(paths, configPath)
+		}
+	}
+	if projectConfigFile != "" {
+		paths = append(paths, projectConfigFile)
+	}
+
+	return paths
+}
+
+// warnConfigProblems prints the problems of the config files, without checking files,
+// so that typos are not silently ignored.
+func warnConfigProblems() {
+	for _, configPath := range loadedConfigFiles() {
+		problems, err := validateConfigFile(configPath, false)
+		if err != nil {
+			continue
+		}
+		for _, problem := range problems {
+			fmt.Fprintln(os.Stderr, problem)
+		}
+	}
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the config files for unknown keys, invalid values and missing files.",
+	Long: `Check the config files for unknown keys, invalid values and missing files.
+
+Both config.toml and the project config .acutils.toml are checked. It fails if any
+error is found, or also on warnings (e.g. unknown keys) in the strict mode.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+
+		paths := loadedConfigFiles()
+		if len(paths) == 0 {
+			fmt.Println("no config file found")
+			return nil
+		}
+
+		failed := 0
+		for _, configPath := range paths {
+			problems, err := validateConfigFile(configPath, true)
+			if err != nil {
+				return err
+			}
+			if len(problems) == 0 {
+				fmt.Printf("%s: ok\n", configPath)
+			}
+			for _, problem := range problems {
+				fmt.Println(problem)
+				if !problem.Warning || IsStrict() {
+					failed++
+				}
+			}
+		}
+		if failed != 0 {
+			return fmt.Errorf("%d problem(s) found in the config", failed)
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,252 @@
+/*
+Copyright © 2024 lemolatoon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/hairyhenderson/go-which"
+	"github.com/lemolatoon/acutils-cli/judge"
+	"github.com/lemolatoon/acutils-cli/shell"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const DEBUG_EXECUTABLE = "debug.out"
+
+const DEBUGGER_KEY = "DEBUGGER"
+const DEBUGGER_DEFAULT = "gdb"
+
+// GetDebugger returns DEBUGGER, the gdb or lldb command used by debug.
+func GetDebugger() string {
+	if debugDebugger != "" {
+		return debugDebugger
+	}
+	if debugger := viper.GetString(DEBUGGER_KEY); debugger != "" {
+		return debugger
+	}
+
+	return DEBUGGER_DEFAULT
+}
+
+func isLLDB(debugger string) bool {
+	return strings.Contains(filepath.Base(debugger), "lldb")
+}
+
+var (
+	debugCase      string
+	debugBacktrace bool
+	debugDebugger  string
+)
+
+var debugCmd = &cobra.Command{
+	Use:   "debug problem-name --case NAME [-- program-arguments...]",
+	Short: "Debug the solution on a test case with gdb or lldb.",
+	Long: `Debug the solution on a test case with gdb or lldb.
+
+main.cpp is compiled to debug.out with a debug profile: the flags in effect without
+the sanitizers and the optimization, with -g -O0 -fno-omit-frame-pointer. The debugger
+(DEBUGGER in config.toml or --debugger, default: gdb) is started with the input of the
+case redirected to the program and the arguments after "--" set, so that "run" runs
+the case. The case is given by its name, or by its number if only one case ends with it,
+e.g. --case 3 for sample-3.
+
+With --backtrace, the case is run in the debugger without interaction instead, and the
+signal and the backtrace at which the program stopped are printed. "acutils-cli test
+--backtrace" prints them for every case judged as RE.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if debugCase == "" {
+			return errors.New("--case must be given")
+		}
+		cmd.SilenceUsage = true
+		directory, programArgs := args[0], args[1:]
+
+		c, err := findCase(directory, debugCase)
+		if err != nil {
+			return err
+		}
+		debugger := GetDebugger()
+		if !dryRun && !which.Found(debugger) {
+			return fmt.Errorf("debugger %s is not found; install it or set %s in config.toml", debugger, DEBUGGER_KEY)
+		}
+		executable, err := compileDebug(directory)
+		if err != nil {
+			return err
+		}
+
+		if debugBacktrace {
+			lines, err := backtrace(debugger, executable, c, programArgs)
+			if err != nil {
+				return err
+			}
+			if !dryRun {
+				fmt.Print(strings.Join(lines, "\n") + "\n")
+			}
+			return nil
+		}
+
+		logf("type \"run\" to run %s with %s\n", executable, c.Input)
+		return runner.Run(shell.Command(debugger, debuggerArgs(debugger, executable, c.Input, programArgs, false)...))
+	},
+}
+
+// debugFlags removes the sanitizers and the optimization from the flags, adding the debug flags.
+// The runtime of the sanitizers catches the faults itself, so the debugger would stop in it
+// instead of at the faulting line.
+func debugFlags(flags []string) []string {
+	debug := make([]string, 0, len(flags)+3)
+	for _, flag := range flags {
+		switch {
+		case strings.HasPrefix(flag, "-fsanitize"), strings.HasPrefix(flag, "-fno-sanitize"),
+			flag == "-g", strings.HasPrefix(flag, "-g") && len(flag) == 3,
+			strings.HasPrefix(flag, "-O"), flag == "-fomit-frame-pointer":
+			continue
+		}
+		debug = append(debug, flag)
+	}
+
+	return append(debug, "-g", "-O0", "-fno-omit-frame-pointer")
+}
+
+// compileDebug compiles main.cpp of the problem to debug.out if needed and returns the path
+// to run it with.
+func compileDebug(directory string) (string, error) {
+	executable := filepath.Join(directory, DEBUG_EXECUTABLE)
+	if checkIfShouldCompile(sourcePath(directory), executable) {
+		flags := debugFlags(GetCXXFLAGS())
+		logf("compiling %s with %s\n", executable, strings.Join(flags, " "))
+		if err := compileWithFlags(sourcePath(directory), executable, flags); err != nil {
+			return "", err
+		}
+	}
+
+	return commandPath(executable), nil
+}
+
+// debuggerArgs returns the arguments of the debugger to debug the executable with the input
+// redirected and the arguments set. With batch, the program is run and the backtrace is
+// printed where it stops, without interaction.
+func debuggerArgs(debugger string, executable string, input string, args []string, batch bool) []string {
+	if isLLDB(debugger) {
+		debuggerArgs := []string{"-o", "settings set target.input-path " + lldbQuote(input)}
+		if batch {
+			debuggerArgs = append([]string{"--batch"}, debuggerArgs...)
+			debuggerArgs = append(debuggerArgs, "-o", "run", "-k", "bt")
+		}
+		return append(append(debuggerArgs, "--", executable), args...)
+	}
+
+	// gdb starts the program with the shell, which redirects the input.
+	programArgs := "< " + shell.Quote(input)
+	if len(args) != 0 {
+		programArgs = shell.Join(args) + " " + programArgs
+	}
+	debuggerArgs := []string{"-q", "-ex", "set args " + programArgs}
+	if batch {
+		debuggerArgs = append([]string{"-batch"}, debuggerArgs...)
+		debuggerArgs = append(debuggerArgs, "-ex", "run", "-ex", "bt")
+	}
+
+	return append(debuggerArgs, executable)
+}
+
+// lldbQuote quotes the argument of an lldb command with double quotes.
+func lldbQuote(arg string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+}
+
+// backtracePattern matches the lines of the output of gdb and lldb telling where and why
+// the program stopped: the signal, the frames, and the exit status if it did not stop.
+var backtracePattern = regexp.MustCompile(`^(#\d+\s|Program (received|terminated with) signal|\[Inferior \d+ \(process \d+\) exited|\s*\*?\s*frame #\d+|\* thread #|Process \d+ exited)`)
+
+// backtrace runs the case in the debugger without interaction and returns the lines telling
+// where the program stopped. The program runs with the CPU time limit of the sandbox,
+// so that an infinite loop stops with SIGXCPU and its backtrace too.
+func backtrace(debugger string, executable string, c judge.Case, args []string) ([]string, error) {
+	var output bytes.Buffer
+	debug := &shell.Cmd{
+		Path:   debugger,
+		Args:   debuggerArgs(debugger, executable, c.Input, args, true),
+		Stdout: &output,
+		Stderr: &output,
+		Limits: sandboxLimits(3 * GetTimeLimit()),
+	}
+	var exitErr *exec.ExitError
+	// The debugger fails on "bt" after the program exited normally.
+	if err := runner.Run(debug); err != nil && !errors.As(err, &exitErr) {
+		return nil, fmt.Errorf("failed to run %s: %w", debugger, err)
+	}
+
+	return backtraceLines(output.String()), nil
+}
+
+func backtraceLines(output string) []string {
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		if backtracePattern.MatchString(line) {
+			lines = append(lines, strings.TrimRight(line, " \r"))
+		}
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "no backtrace: the program did not stop in the debugger")
+	}
+
+	return lines
+}
+
+// printBacktraces prints the backtraces of the cases judged as RE, for test --backtrace.
+func printBacktraces(directory string, results []judge.Result) {
+	var executable string
+	for _, result := range results {
+		if result.Verdict != judge.RE {
+			continue
+		}
+		if executable == "" {
+			var err error
+			if executable, err = compileDebug(directory); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: cannot compile %s: %v\n", DEBUG_EXECUTABLE, err)
+				return
+			}
+		}
+		lines, err := backtrace(GetDebugger(), executable, result.Case, nil)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+			return
+		}
+		fmt.Printf("%s backtrace:\n%s", result.Case.Name, indent([]byte(strings.Join(lines, "\n"))))
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(debugCmd)
+	debugCmd.Flags().StringVar(&debugCase, "case", "", "name or number of the test case to debug")
+	debugCmd.Flags().BoolVar(&debugBacktrace, "backtrace", false, "print the backtrace where the program stops, without interaction")
+	debugCmd.Flags().StringVar(&debugDebugger, "debugger", "", "gdb or lldb command (overrides DEBUGGER)")
+}
@@ -0,0 +1,187 @@
+/*
+Copyright © 2024 lemolatoon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lemolatoon/acutils-cli/diff"
+	"github.com/lemolatoon/acutils-cli/judge"
+	"github.com/lemolatoon/acutils-cli/sandbox"
+	"github.com/spf13/cobra"
+)
+
+var diffSolutionsCmd = &cobra.Command{
+	Use:   "diff-solutions problem-name first.cpp second.cpp",
+	Short: "Compare the outputs and the times of two solutions on the test cases.",
+	Long: `Compare the outputs and the times of two solutions on the test cases.
+
+Both solutions are compiled, main.cpp to a.out and the others to <name>.out next to
+them, and run one after the other in the sandbox on the input of every test case of
+the problem. The expected outputs are not used: a case is shown as DIFF when the
+outputs differ, with the diff of the second output against the first one, and with
+the verdicts when either run fails. The ratio is the time of the second solution
+over the time of the first one.
+
+The sources are looked up in the problem directory first, e.g.
+
+  acutils-cli diff-solutions a main.cpp main2.cpp`,
+	Args: cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		directory := args[0]
+
+		var executables [2]string
+		var names [2]string
+		for i, name := range args[1:] {
+			source, err := resolveSource(directory, name)
+			if err != nil {
+				return err
+			}
+			executables[i], err = compileSolution(directory, source)
+			if err != nil {
+				return err
+			}
+			names[i] = filepath.Base(source)
+		}
+		if names[0] == names[1] {
+			names = [2]string{args[1], args[2]}
+		}
+
+		cases, err := judge.Discover(directory)
+		if err != nil {
+			return err
+		}
+		if len(cases) == 0 {
+			return fmt.Errorf("no test cases in %s", testsDir(directory))
+		}
+		if dryRun {
+			return nil
+		}
+
+		timeLimit := GetTimeLimit()
+		var limits sandbox.Limits
+		if sandboxed := sandboxLimits(timeLimit); sandboxed != nil {
+			limits = *sandboxed
+		}
+		diffOptions := diff.DefaultOptions
+		diffOptions.Color = colorEnabled(os.Stdout)
+
+		width := max(12, len(names[0])+2, len(names[1])+2)
+		fmt.Printf("%-16s%*s%*s%8s\n", "case", width, names[0], width, names[1], "ratio")
+		var totals [2]time.Duration
+		mismatches := 0
+		for _, c := range cases {
+			// Only the outputs of the solutions are compared.
+			c.Output = ""
+			var results [2]judge.Result
+			for i, executable := range executables {
+				results[i], err = judge.RunCase(executable, c, timeLimit, limits)
+				if err != nil {
+					return err
+				}
+				totals[i] += results[i].Time
+			}
+
+			status := compareSolutions(results[0], results[1])
+			if status != "same" {
+				mismatches++
+			}
+			fmt.Printf("%-16s%*s%*s%8s  %s\n", c.Name, width, formatDuration(results[0].Time), width, formatDuration(results[1].Time),
+				formatRatio(results[0].Time, results[1].Time), status)
+			if status == "DIFF" {
+				var b strings.Builder
+				_ = diff.Write(&b, results[0].Stdout, results[1].Stdout, diffOptions)
+				fmt.Print(indent([]byte(b.String())))
+			}
+		}
+		fmt.Printf("%-16s%*s%*s%8s\n", "total", width, formatDuration(totals[0]), width, formatDuration(totals[1]), formatRatio(totals[0], totals[1]))
+
+		if mismatches != 0 {
+			return fmt.Errorf("%s differs from %s on %d of %d cases", names[1], names[0], mismatches, len(cases))
+		}
+		fmt.Printf("%s matches %s on all %d cases\n", names[1], names[0], len(cases))
+
+		return nil
+	},
+}
+
+// resolveSource returns the path to the source, looked up in the problem directory first.
+func resolveSource(directory string, name string) (string, error) {
+	for _, source := range []string{filepath.Join(directory, name), name} {
+		if info, err := os.Stat(source); err == nil && !info.IsDir() {
+			return source, nil
+		}
+	}
+
+	return "", fmt.Errorf("source file %s is found neither in %s nor in the current directory", name, directory)
+}
+
+// compileSolution compiles the source if needed and returns the path to run its executable with.
+// main.cpp of the problem is compiled to a.out, and the other sources to <name>.out next to them.
+func compileSolution(directory string, source string) (string, error) {
+	if filepath.Clean(source) == filepath.Clean(sourcePath(directory)) {
+		if err := compileIfNeeded(directory, false); err != nil {
+			return "", err
+		}
+		return commandPath(executablePath(directory)), nil
+	}
+
+	executable := strings.TrimSuffix(source, filepath.Ext(source)) + ".out"
+	if checkIfShouldCompile(source, executable) {
+		if err := compile(source, executable); err != nil {
+			return "", err
+		}
+	}
+
+	return commandPath(executable), nil
+}
+
+// compareSolutions returns "same" when both runs succeed with the same output, "DIFF" when
+// their outputs differ, and the verdicts such as "OK/RE" when either run fails.
+func compareSolutions(first judge.Result, second judge.Result) string {
+	if first.Verdict != judge.OK || second.Verdict != judge.OK {
+		return fmt.Sprintf("%s/%s", first.Verdict, second.Verdict)
+	}
+	if !judge.Equal(first.Stdout, second.Stdout) {
+		return "DIFF"
+	}
+
+	return "same"
+}
+
+// formatRatio formats the time of the second solution over the first one, e.g. "0.50x".
+func formatRatio(first time.Duration, second time.Duration) string {
+	if first <= 0 {
+		return "-"
+	}
+
+	return fmt.Sprintf("%.2fx", float64(second)/float64(first))
+}
+
+func init() {
+	rootCmd.AddCommand(diffSolutionsCmd)
+}
@@ -0,0 +1,386 @@
+/*
+Copyright © 2024 lemolatoon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/hairyhenderson/go-which"
+	"github.com/spf13/cobra"
+)
+
+// DoctorStatus is the result of a doctor check.
+type DoctorStatus string
+
+const (
+	DOCTOR_PASS DoctorStatus = "PASS"
+	DOCTOR_WARN DoctorStatus = "WARN"
+	DOCTOR_FAIL DoctorStatus = "FAIL"
+)
+
+// DOCTOR_COMPILE_TIMEOUT limits each probe compilation of doctor.
+const DOCTOR_COMPILE_TIMEOUT = time.Minute
+
+// DoctorCheck is a line of the doctor report.
+type DoctorCheck struct {
+	Name   string
+	Status DoctorStatus
+	Detail string
+	// Hint tells how to fix the problem, if it is not passed.
+	Hint string
+}
+
+// doctorCmd represents the doctor command
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the local toolchain and the configuration.",
+	Long: `Check the local toolchain and the configuration.
+
+Check that the compiler (CXX) exists, that bits/stdc++.h compiles, that the
+sanitizers and the libraries of the judge preset link, whether ac-library and Boost are found, which clipboard
+commands clip and run --from-clipboard use, and that the config files are
+valid. Each check is reported as PASS, WARN or FAIL with a hint to fix it.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+
+		checks := runDoctorChecks()
+		printDoctorReport(os.Stdout, checks)
+		for _, check := range checks {
+			if check.Status == DOCTOR_FAIL {
+				return fmt.Errorf("some checks failed")
+			}
+		}
+
+		return nil
+	},
+}
+
+func runDoctorChecks() []DoctorCheck {
+	cxx := GetCXX()
+	compilerCheck := checkCompiler(cxx)
+	checks := []DoctorCheck{compilerCheck}
+
+	probes := []struct {
+		name  string
+		check func(string) DoctorCheck
+	}{
+		{"bits/stdc++.h", checkBitsStdcxx},
+		{"sanitizers", checkSanitizers},
+		{"link libraries", checkLinkLibraries},
+		{"ac-library", checkACLibrary},
+		{"boost", checkBoost},
+	}
+	for _, probe := range probes {
+		if compilerCheck.Status == DOCTOR_FAIL {
+			checks = append(checks, DoctorCheck{Name: probe.name, Status: DOCTOR_WARN, Detail: "skipped, the compiler is not available"})
+			continue
+		}
+		checks = append(checks, probe.check(cxx))
+	}
+
+	return append(checks, checkClipboard(), checkConfig())
+}
+
+func checkCompiler(cxx string) DoctorCheck {
+	check := DoctorCheck{Name: "compiler"}
+	name := compilerArgv(cxx)[0]
+	path, err := exec.LookPath(name)
+	if err != nil {
+		check.Status = DOCTOR_FAIL
+		check.Detail = fmt.Sprintf("%s is not found", name)
+		check.Hint = "install g++ or clang++, or set the compiler with `acutils-cli config set CXX g++-13`"
+		return check
+	}
+	version, err := GetCompilerVersion(cxx)
+	if err != nil {
+		check.Status = DOCTOR_WARN
+		check.Detail = fmt.Sprintf("%s (%v)", path, err)
+		return check
+	}
+
+	check.Status = DOCTOR_PASS
+	check.Detail = fmt.Sprintf("%s (%s %s)", path, version.Family, version.Version)
+	if preset := currentJudgePreset(); preset != nil {
+		if warning := checkJudgeCompiler(preset, version); warning != "" {
+			check.Status = DOCTOR_WARN
+			check.Detail += ", " + warning
+			check.Hint = fmt.Sprintf("install %s or set CXX to it", preset.CXX)
+		}
+	}
+
+	return check
+}
+
+func checkBitsStdcxx(cxx string) DoctorCheck {
+	check := DoctorCheck{Name: "bits/stdc++.h"}
+	if output, err := probeCompile(cxx, "#include <bits/stdc++.h>\nint main() {}\n", false, false, nil); err != nil {
+		check.Status = DOCTOR_FAIL
+		check.Detail = firstErrorLine(output, err)
+		if runtime.GOOS == "darwin" {
+			check.Hint = "Apple clang has no bits/stdc++.h; `brew install gcc` and set CXX to g++-14 (not g++, which is clang)"
+		} else {
+			check.Hint = "install libstdc++ (e.g. `apt install g++`), or use g++ as CXX"
+		}
+		return check
+	}
+
+	check.Status = DOCTOR_PASS
+	check.Detail = "compiles"
+	return check
+}
+
+func checkSanitizers(cxx string) DoctorCheck {
+	check := DoctorCheck{Name: "sanitizers"}
+	used := false
+	for _, flag := range GetCXXFLAGS() {
+		if strings.HasPrefix(flag, "-fsanitize=") {
+			used = true
+		}
+	}
+
+	if output, err := probeCompile(cxx, "int main() {}\n", true, true, nil); err != nil {
+		check.Status = DOCTOR_WARN
+		if used {
+			check.Status = DOCTOR_FAIL
+		}
+		check.Detail = "-fsanitize=address,undefined: " + firstErrorLine(output, err)
+		check.Hint = "install the sanitizer runtimes (e.g. `apt install libgcc-13-dev`), or remove -fsanitize from CXXFLAGS"
+		return check
+	}
+
+	check.Status = DOCTOR_PASS
+	check.Detail = "AddressSanitizer and UndefinedBehaviorSanitizer link and run"
+	if !used {
+		check.Detail += " (not enabled in CXXFLAGS)"
+	}
+	return check
+}
+
+// checkLinkLibraries checks the link flags of the judge preset (e.g. -lgmp), which are
+// probed apart from the sanitizers, so that a missing library is reported as itself.
+func checkLinkLibraries(cxx string) DoctorCheck {
+	check := DoctorCheck{Name: "link libraries"}
+	linkFlags := GetLinkFlags()
+	if len(linkFlags) == 0 {
+		check.Status = DOCTOR_PASS
+		check.Detail = "no link flags"
+		return check
+	}
+
+	if output, err := probeCompile(cxx, "int main() {}\n", true, false, linkFlags); err != nil {
+		check.Status = DOCTOR_FAIL
+		check.Detail = strings.Join(linkFlags, " ") + ": " + firstErrorLine(output, err)
+		check.Hint = "install the libraries of the judge preset (e.g. `apt install libgmp-dev`), or set CXXFLAGS to compile without them"
+		return check
+	}
+
+	check.Status = DOCTOR_PASS
+	check.Detail = strings.Join(linkFlags, " ") + " link"
+	return check
+}
+
+func checkACLibrary(cxx string) DoctorCheck {
+	return checkHeader(cxx, "ac-library", "atcoder/all",
+		"clone https://github.com/atcoder/ac-library and add it to INCLUDE_PATHS")
+}
+
+func checkBoost(cxx string) DoctorCheck {
+	return checkHeader(cxx, "boost", "boost/version.hpp",
+		"install Boost (e.g. `apt install libboost-dev` or `brew install boost`) or add it to INCLUDE_PATHS")
+}
+
+// checkHeader checks an optional library, which is a warning if it is missing.
+func checkHeader(cxx string, name string, header string, hint string) DoctorCheck {
+	check := DoctorCheck{Name: name}
+	if output, err := probeCompile(cxx, fmt.Sprintf("#include <%s>\nint main() {}\n", header), false, false, nil); err != nil {
+		check.Status = DOCTOR_WARN
+		check.Detail = fmt.Sprintf("<%s> is not found: %s", header, firstErrorLine(output, err))
+		check.Hint = hint
+		return check
+	}
+
+	check.Status = DOCTOR_PASS
+	check.Detail = fmt.Sprintf("<%s> is found", header)
+	return check
+}
+
+// probeCompile compiles the source with the configured flags. Without link, only the syntax is
+// checked. With link, the executable is linked with the link flags and also run.
+// With sanitize, the sanitizers are enabled.
+func probeCompile(cxx string, source string, link bool, sanitize bool, linkFlags []string) (string, error) {
+	dir, err := os.MkdirTemp("", "acutils-doctor-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	sourceFile := filepath.Join(dir, "probe.cpp")
+	if err := os.WriteFile(sourceFile, []byte(source), 0644); err != nil {
+		return "", err
+	}
+
+	var args []string
+	for _, flag := range GetCXXFLAGS() {
+		if !strings.HasPrefix(flag, "-fsanitize") {
+			args = append(args, flag)
+		}
+	}
+	if sanitize {
+		args = append(args, "-fsanitize=address,undefined")
+	}
+	executable := filepath.Join(dir, "probe")
+	if link {
+		args = append(append(args, sourceFile, "-o", executable), linkFlags...)
+	} else {
+		args = append(args, "-fsyntax-only", sourceFile)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), DOCTOR_COMPILE_TIMEOUT)
+	defer cancel()
+	argv := compilerArgv(cxx, args...)
+	if output, err := exec.CommandContext(ctx, argv[0], argv[1:]...).CombinedOutput(); err != nil {
+		return string(output), err
+	}
+	if link {
+		if output, err := exec.CommandContext(ctx, executable).CombinedOutput(); err != nil {
+			return string(output), fmt.Errorf("the executable failed: %w", err)
+		}
+	}
+
+	return "", nil
+}
+
+// firstErrorLine returns the first error in the compiler output without the location
+// in the temporary directory, or err. A missing library is reported by the linker before
+// "collect2: error: ld returned 1 exit status".
+func firstErrorLine(output string, err error) string {
+	for _, line := range strings.Split(output, "\n") {
+		for _, marker := range []string{"fatal error:", "cannot find -l", "error:", "ERROR:"} {
+			if i := strings.Index(line, marker); i >= 0 {
+				return strings.TrimSpace(line[i:])
+			}
+		}
+	}
+
+	return err.Error()
+}
+
+func checkClipboard() DoctorCheck {
+	check := DoctorCheck{Name: "clipboard"}
+	var writer, reader string
+	for _, name := range []string{"clip.exe", "pbcopy"} {
+		if which.Found(name) {
+			writer = name
+			break
+		}
+	}
+	for _, command := range clipboardReaders {
+		if which.Found(command[0]) {
+			reader = command[0]
+			break
+		}
+	}
+
+	switch {
+	case writer != "" && reader != "":
+		check.Status = DOCTOR_PASS
+		check.Detail = fmt.Sprintf("clip uses %s, run --from-clipboard uses %s", writer, reader)
+	case writer != "":
+		check.Status = DOCTOR_WARN
+		check.Detail = fmt.Sprintf("clip uses %s, but run --from-clipboard has no command (tried %s)", writer, clipboardReaderNames())
+		check.Hint = "install wl-clipboard, xclip or xsel"
+	default:
+		check.Status = DOCTOR_WARN
+		check.Detail = "clip prints the source instead of copying it (neither clip.exe nor pbcopy is found)"
+		if reader != "" {
+			check.Detail += fmt.Sprintf(", run --from-clipboard uses %s", reader)
+		}
+		check.Hint = "clip supports clip.exe (WSL) and pbcopy (macOS)"
+	}
+
+	return check
+}
+
+func checkConfig() DoctorCheck {
+	check := DoctorCheck{Name: "config"}
+	paths := loadedConfigFiles()
+	if len(paths) == 0 {
+		check.Status = DOCTOR_PASS
+		path, _ := configFilePath()
+		check.Detail = fmt.Sprintf("no config file, using the defaults (%s is not created yet)", path)
+		return check
+	}
+
+	check.Status = DOCTOR_PASS
+	errors, warnings := 0, 0
+	for _, path := range paths {
+		problems, err := validateConfigFile(path, true)
+		if err != nil {
+			check.Status = DOCTOR_FAIL
+			check.Detail = err.Error()
+			return check
+		}
+		for _, problem := range problems {
+			if problem.Warning {
+				warnings++
+			} else {
+				errors++
+			}
+		}
+	}
+	check.Detail = strings.Join(paths, ", ")
+	if errors+warnings != 0 {
+		check.Status = DOCTOR_WARN
+		if errors != 0 {
+			check.Status = DOCTOR_FAIL
+		}
+		check.Detail += fmt.Sprintf(" (%d error(s), %d warning(s))", errors, warnings)
+		check.Hint = "run `acutils-cli config validate` for the details"
+	}
+
+	return check
+}
+
+func printDoctorReport(w io.Writer, checks []DoctorCheck) {
+	counts := map[DoctorStatus]int{}
+	for _, check := range checks {
+		counts[check.Status]++
+		fmt.Fprintf(w, "[%s] %-14s %s\n", check.Status, check.Name, check.Detail)
+		if check.Hint != "" && check.Status != DOCTOR_PASS {
+			fmt.Fprintf(w, "       %-14s hint: %s\n", "", check.Hint)
+		}
+	}
+	fmt.Fprintf(w, "\n%d passed, %d warning(s), %d failed\n", counts[DOCTOR_PASS], counts[DOCTOR_WARN], counts[DOCTOR_FAIL])
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
@@ -0,0 +1,135 @@
+/*
+Copyright © 2024 lemolatoon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lemolatoon/acutils-cli/gen"
+	"github.com/lemolatoon/acutils-cli/judge"
+	"github.com/spf13/cobra"
+)
+
+// GEN_SPEC_FILE is the file in a problem directory which has the generator spec.
+const GEN_SPEC_FILE = "gen.spec"
+
+var (
+	genSeed int64
+	genSpec string
+	genSave bool
+)
+
+var genCmd = &cobra.Command{
+	Use:   "gen problem-name",
+	Short: "Generate a random input from the generator spec of the problem.",
+	Long: `Generate a random input from the generator spec of the problem.
+
+The spec is read from gen.spec in the problem directory, or given by --spec.
+Each line of the spec is a line of the input, e.g.
+
+  N:int[2,2e5] M:int[N-1,N*(N-1)/2]
+  A:int[1,1e9]*N
+  graph(N,M)
+
+Items:
+  int[lo,hi]        an integer in [lo, hi]
+  str[lo,hi]@abc    a string of length in [lo, hi] over the alphabet (default: a-z), e.g. @a-z0-9
+  perm(n)           a permutation of 1..n
+  tree(n)           n-1 lines of the edges "u v" of a random tree on 1..n
+  graph(n,m)        m lines of the edges of a random connected graph without multi-edges
+
+"NAME:" names an int to use in the later bounds, "*count" repeats an item on the
+line and "{ items }*count" repeats a line. tree and graph take an optional weight,
+e.g. tree(N,int[1,1e9]). Lines may also be separated by ";" and "#" starts a comment.
+
+The same --seed always generates the same input. Without --seed, a random seed is
+used and printed to stderr. With --save, the input is saved as the next custom case.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		directory := args[0]
+
+		spec, err := loadGenSpec(directory)
+		if err != nil {
+			return err
+		}
+		seed := genSeed
+		if !cmd.Flags().Changed("seed") {
+			seed = time.Now().UnixNano()
+			logf("seed: %d\n", seed)
+		}
+		var input bytes.Buffer
+		if err := spec.Generate(&input, seed); err != nil {
+			return err
+		}
+
+		if !genSave {
+			_, err := os.Stdout.Write(input.Bytes())
+			return err
+		}
+		c, err := judge.NextCustomCase(directory)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(testsDir(directory), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(c.Input, input.Bytes(), 0644); err != nil {
+			return err
+		}
+		fmt.Printf("saved %s (seed %d)\n", c.Input, seed)
+		return nil
+	},
+}
+
+// loadGenSpec parses --spec, or gen.spec in the problem directory.
+func loadGenSpec(directory string) (*gen.Spec, error) {
+	if genSpec != "" {
+		return gen.Parse(genSpec)
+	}
+
+	specPath := filepath.Join(directory, GEN_SPEC_FILE)
+	content, err := os.ReadFile(specPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%s does not exist; write the generator spec in it or pass --spec", specPath)
+	} else if err != nil {
+		return nil, err
+	}
+	spec, err := gen.Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", specPath, err)
+	}
+
+	return spec, nil
+}
+
+func init() {
+	rootCmd.AddCommand(genCmd)
+	genCmd.Flags().Int64Var(&genSeed, "seed", 0, "seed of the random input (default: random)")
+	genCmd.Flags().StringVar(&genSpec, "spec", "", "generator spec to use instead of gen.spec")
+	genCmd.Flags().BoolVar(&genSave, "save", false, "save the input as the next custom case instead of printing it")
+}
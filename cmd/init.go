@@ -43,9 +43,13 @@ Create new directory for the contest. This command will also put
 		if len(args) != 1 {
 			return errors.New(`contest-name must be provided`)
 		}
+		cmd.SilenceUsage = true
 		directory := args[0]
 
-		settingsJsonContent := GetVscodeSettingsFileContent()
+		settingsJsonContent, err := GetVscodeSettingsFileContent()
+		if err != nil {
+			return err
+		}
 
 		if err := os.Mkdir(directory, 0755); err != nil {
 			return err
@@ -0,0 +1,279 @@
+/*
+Copyright © 2024 lemolatoon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lemolatoon/acutils-cli/shell"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const JUDGE_PRESET_KEY = "JUDGE_PRESET"
+const INCLUDE_PATHS_KEY = "INCLUDE_PATHS"
+
+// JudgePreset describes the compiler environment of a judge language entry.
+type JudgePreset struct {
+	Name     string
+	Language string
+	// Family is "gcc" or "clang".
+	Family       string
+	MajorVersion int
+	CXX          string
+	CXXFLAGS     []string
+	// IncludePaths are the library paths on the judge server.
+	// They can be replaced with local ones by INCLUDE_PATHS.
+	IncludePaths []string
+	LinkFlags    []string
+}
+
+// JUDGE_PRESETS are taken from the AtCoder 2023 language update.
+var JUDGE_PRESETS = map[string]JudgePreset{
+	"atcoder-gcc": {
+		Name:         "atcoder-gcc",
+		Language:     "C++ 23 (gcc 12.2)",
+		Family:       "gcc",
+		MajorVersion: 12,
+		CXX:          "g++-12",
+		CXXFLAGS: []string{
+			"-std=gnu++2b", "-O2", "-DONLINE_JUDGE", "-DATCODER",
+			"-Wall", "-Wextra", "-mtune=native", "-march=native",
+			"-fconstexpr-depth=2147483647", "-fconstexpr-loop-limit=2147483647", "-fconstexpr-ops-limit=2147483647",
+		},
+		IncludePaths: []string{"/opt/ac-library", "/opt/boost/gcc/include", "/usr/include/eigen3"},
+		LinkFlags:    []string{"-L/opt/boost/gcc/lib", "-lgmpxx", "-lgmp"},
+	},
+	"atcoder-gcc-cpp20": {
+		Name:         "atcoder-gcc-cpp20",
+		Language:     "C++ 20 (gcc 12.2)",
+		Family:       "gcc",
+		MajorVersion: 12,
+		CXX:          "g++-12",
+		CXXFLAGS: []string{
+			"-std=gnu++20", "-O2", "-DONLINE_JUDGE", "-DATCODER",
+			"-Wall", "-Wextra", "-mtune=native", "-march=native",
+			"-fconstexpr-depth=2147483647", "-fconstexpr-loop-limit=2147483647", "-fconstexpr-ops-limit=2147483647",
+		},
+		IncludePaths: []string{"/opt/ac-library", "/opt/boost/gcc/include", "/usr/include/eigen3"},
+		LinkFlags:    []string{"-L/opt/boost/gcc/lib", "-lgmpxx", "-lgmp"},
+	},
+	"atcoder-clang": {
+		Name:         "atcoder-clang",
+		Language:     "C++ 20 (Clang 16.0.6)",
+		Family:       "clang",
+		MajorVersion: 16,
+		CXX:          "clang++",
+		CXXFLAGS: []string{
+			"-std=c++20", "-O2", "-DONLINE_JUDGE", "-DATCODER",
+			"-Wall", "-Wextra", "-mtune=native", "-march=native",
+			"-fconstexpr-depth=2147483647", "-fconstexpr-steps=2147483647",
+		},
+		IncludePaths: []string{"/opt/ac-library", "/opt/boost/clang/include", "/usr/include/eigen3"},
+		LinkFlags:    []string{"-L/opt/boost/clang/lib", "-fuse-ld=lld"},
+	},
+}
+
+// GetJudgePreset returns the preset selected by JUDGE_PRESET, or nil if no preset is selected.
+func GetJudgePreset() (*JudgePreset, error) {
+	name := viper.GetString(JUDGE_PRESET_KEY)
+	if name == "" {
+		return nil, nil
+	}
+	preset, ok := JUDGE_PRESETS[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown judge preset %q (available: %s)", name, strings.Join(judgePresetNames(), ", "))
+	}
+
+	return &preset, nil
+}
+
+func judgePresetNames() []string {
+	names := make([]string, 0, len(JUDGE_PRESETS))
+	for name := range JUDGE_PRESETS {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// judgePreset is the preset of the command, resolved once by resolveJudgePreset before it runs.
+var judgePreset *JudgePreset
+
+// resolveJudgePreset resolves JUDGE_PRESET (or --judge) for the command, so that an unknown
+// preset fails the command instead of compiling with the default flags.
+func resolveJudgePreset() error {
+	preset, err := GetJudgePreset()
+	judgePreset = preset
+
+	return err
+}
+
+// currentJudgePreset returns the preset resolved for the command, or nil if no preset is selected.
+func currentJudgePreset() *JudgePreset {
+	return judgePreset
+}
+
+// GetIncludePaths returns INCLUDE_PATHS, or the include paths of the judge preset.
+func GetIncludePaths() []string {
+	return includePaths(currentJudgePreset())
+}
+
+// includePaths is GetIncludePaths with the preset already resolved.
+func includePaths(preset *JudgePreset) []string {
+	if paths := viper.GetStringSlice(INCLUDE_PATHS_KEY); len(paths) != 0 {
+		return paths
+	}
+	if preset != nil {
+		return preset.IncludePaths
+	}
+
+	return nil
+}
+
+// CompilerVersion is the compiler family and version reported by `--version`.
+type CompilerVersion struct {
+	Family  string
+	Version string
+	Major   int
+}
+
+var compilerVersionPattern = regexp.MustCompile(`(\d+)\.\d+(\.\d+)?`)
+
+func parseCompilerVersion(output string) (CompilerVersion, error) {
+	firstLine, _, _ := strings.Cut(output, "\n")
+	family := "gcc"
+	if strings.Contains(strings.ToLower(firstLine), "clang") {
+		family = "clang"
+	}
+	match := compilerVersionPattern.FindStringSubmatch(firstLine)
+	if match == nil {
+		return CompilerVersion{}, fmt.Errorf("cannot find compiler version in %q", firstLine)
+	}
+	major, err := strconv.Atoi(match[1])
+	if err != nil {
+		return CompilerVersion{}, err
+	}
+
+	return CompilerVersion{Family: family, Version: match[0], Major: major}, nil
+}
+
+func GetCompilerVersion(cxx string) (CompilerVersion, error) {
+	argv := compilerArgv(cxx, "--version")
+	verbosef("+%s\n", shell.Join(argv))
+	output, err := exec.Command(argv[0], argv[1:]...).Output()
+	if err != nil {
+		return CompilerVersion{}, fmt.Errorf("failed to run %s --version: %w", cxx, err)
+	}
+
+	return parseCompilerVersion(string(output))
+}
+
+// checkJudgeCompiler returns a warning message when the local compiler differs from the judge's one.
+func checkJudgeCompiler(preset *JudgePreset, local CompilerVersion) string {
+	if local.Family != preset.Family || local.Major != preset.MajorVersion {
+		return fmt.Sprintf("local compiler is %s %s, but judge %s uses %s %d (%s)",
+			local.Family, local.Version, preset.Name, preset.Family, preset.MajorVersion, preset.Language)
+	}
+
+	return ""
+}
+
+// warnJudgeCompilerMismatch prints a warning when the compiler differs from the selected judge preset.
+func warnJudgeCompilerMismatch() {
+	preset := currentJudgePreset()
+	if preset == nil {
+		return
+	}
+	local, err := GetCompilerVersion(GetCXX())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+		return
+	}
+	if warning := checkJudgeCompiler(preset, local); warning != "" {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+	}
+}
+
+// judgeCmd represents the judge command
+var judgeCmd = &cobra.Command{
+	Use:   "judge [preset-name]",
+	Short: "Show judge presets and check the local compiler against them.",
+	Long: `Show judge presets and check the local compiler against them.
+
+Without arguments, list the available presets. With a preset name (or with
+JUDGE_PRESET in config.toml), show the compiler and flags of the preset and
+check whether the local compiler matches the judge's one.
+
+Set JUDGE_PRESET in config.toml (or pass --judge) to compile with the flags of
+the preset. The judge's library paths can be replaced with local ones with
+INCLUDE_PATHS.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 1 {
+			return fmt.Errorf("too many arguments")
+		}
+		if len(args) == 0 && viper.GetString(JUDGE_PRESET_KEY) == "" {
+			for _, name := range judgePresetNames() {
+				fmt.Printf("%-20s%s\n", name, JUDGE_PRESETS[name].Language)
+			}
+			return nil
+		}
+		cmd.SilenceUsage = true
+		if len(args) == 1 {
+			viper.Set(JUDGE_PRESET_KEY, args[0])
+		}
+		if err := resolveJudgePreset(); err != nil {
+			return err
+		}
+		preset := currentJudgePreset()
+
+		fmt.Printf("preset:   %s (%s)\n", preset.Name, preset.Language)
+		fmt.Printf("compiler: %s\n", GetCXX())
+		fmt.Printf("flags:    %s\n", strings.Join(GetCXXFLAGS(), " "))
+		if linkFlags := GetLinkFlags(); len(linkFlags) != 0 {
+			fmt.Printf("link:     %s\n", strings.Join(linkFlags, " "))
+		}
+
+		local, err := GetCompilerVersion(GetCXX())
+		if err != nil {
+			return err
+		}
+		if warning := checkJudgeCompiler(preset, local); warning != "" {
+			fmt.Printf("warning:  %s\n", warning)
+		} else {
+			fmt.Printf("local compiler matches the judge (%s %s)\n", local.Family, local.Version)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(judgeCmd)
+}
@@ -24,9 +24,8 @@ package cmd
 import (
 	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
 
+	"github.com/lemolatoon/acutils-cli/history"
 	"github.com/spf13/cobra"
 )
 
@@ -34,36 +33,58 @@ import (
 var newCmd = &cobra.Command{
 	Use:   "new problem-name",
 	Short: "create directory for the problem, and put the template source file in it.",
-	Long:  `create directory for the problem, and put the template source file in it.`,
+	Long: `create directory for the problem, and put the template source file in it.
+
+The template is either a file, which becomes main.cpp, or a directory, whose files
+(e.g. main.cpp, naive.cpp, gen.cpp, .clang-format) are copied recursively with
+their file modes. Named template directories in $HOME/.acutils-cli/templates
+can be chosen with --template-set, and $HOME/.acutils-cli/templates/default
+is used if it exists.
+
+TEMPLATE_RULES in config.toml selects the template by the contest and problem names,
+e.g. a minimal template for "abc*/[a-b]" and a heavy one for "agc*/*".
+--template and --template-set override the rules.
+
+Template files ending with .tmpl (e.g. main.cpp.tmpl, or TEMPLATE_FILE = "template.cpp.tmpl")
+are rendered with Go's text/template and written without the suffix. Other files are
+copied as they are. The available values are
+{{.Contest}}, {{.Problem}}, {{.URL}}, {{.Date}}, {{.Author}} (AUTHOR in config.toml),
+{{.TimeLimit}} (TIME_LIMIT in config.toml) and {{.Vars.name}} for the values
+in the TEMPLATE_VARS table of config.toml.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if len(args) != 1 {
 			return errors.New("problem-name must be provided")
 		}
+		cmd.SilenceUsage = true
 
 		directory := args[0]
 
-		templateSourceContent := GetTemplateFileContent()
-		if templatePath != "" {
-			content, err := os.ReadFile(templatePath)
-			if err != nil {
-				return fmt.Errorf("failed to read template file %s: %w", templatePath, err)
-			}
-			templateSourceContent = string(content)
+		data := newTemplateData(directory)
+		var files []TemplateFile
+		var err error
+		switch {
+		case templatePath != "":
+			files, err = loadTemplate(templatePath)
+		case templateSet != "":
+			files, err = loadTemplateSet(templateSet)
+		default:
+			files, err = GetTemplateFiles(data.Contest, data.Problem)
 		}
-
-		if err := os.Mkdir(directory, 0755); err != nil {
+		if err != nil {
 			return err
 		}
 
-		if err := os.WriteFile(filepath.Join(directory, "main.cpp"), []byte(templateSourceContent), 0644); err != nil {
+		if err := writeTemplateFiles(directory, files, data); err != nil {
 			return err
 		}
+		recordEvent(directory, history.Event{Kind: history.EVENT_NEW})
 
 		return nil
 	},
 }
 
 var templatePath string
+var templateSet string
 
 func init() {
 	rootCmd.AddCommand(newCmd)
@@ -75,4 +96,6 @@ func init() {
 		desc = fmt.Sprintf("%s (default: $HOME/.acutils-cli/template.cpp)", desc)
 	}
 	newCmd.Flags().StringVar(&templatePath, "template", "", desc)
+	newCmd.Flags().StringVar(&templateSet, "template-set", "", "name of the template directory in $HOME/.acutils-cli/templates to copy into the new problem")
+	newCmd.MarkFlagsMutuallyExclusive("template", "template-set")
 }
@@ -0,0 +1,121 @@
+/*
+Copyright © 2024 lemolatoon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// PROJECT_CONFIG_FILE is the config file of a contest or a repository, which is found by
+// walking up from the working directory and merged over the global config.
+const PROJECT_CONFIG_FILE = ".acutils.toml"
+
+// projectConfigFile is the project config merged by initConfig, if any.
+var projectConfigFile string
+
+// projectConfigKeys are the keys set by the project config, in lower case.
+var projectConfigKeys = map[string]bool{}
+
+// findProjectConfig returns the nearest PROJECT_CONFIG_FILE in the directory or its parents.
+func findProjectConfig(directory string) string {
+	dir, err := filepath.Abs(directory)
+	if err != nil {
+		return ""
+	}
+	for {
+		path := filepath.Join(dir, PROJECT_CONFIG_FILE)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// mergeProjectConfig merges the project config over the config read so far.
+// Relative paths in it are resolved against the directory of the project config.
+func mergeProjectConfig(path string) error {
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("toml")
+	if err := v.ReadInConfig(); err != nil {
+		return err
+	}
+
+	settings := v.AllSettings()
+	dir := filepath.Dir(path)
+	for _, key := range CONFIG_KEYS {
+		name := strings.ToLower(key.Name)
+		value, ok := settings[name]
+		if !ok {
+			continue
+		}
+		projectConfigKeys[name] = true
+		if key.Path {
+			settings[name] = resolvePaths(value, dir)
+		}
+	}
+	if rules, ok := settings[strings.ToLower(TEMPLATE_RULES_KEY)].([]any); ok {
+		for _, rule := range rules {
+			if rule, ok := rule.(map[string]any); ok {
+				for field, value := range rule {
+					if strings.EqualFold(field, "template") {
+						rule[field] = resolvePaths(value, dir)
+					}
+				}
+			}
+		}
+	}
+
+	if err := viper.MergeConfigMap(settings); err != nil {
+		return err
+	}
+	projectConfigFile = path
+
+	return nil
+}
+
+// resolvePaths makes a path or a list of paths absolute against the directory.
+func resolvePaths(value any, dir string) any {
+	switch v := value.(type) {
+	case string:
+		if v == "" || filepath.IsAbs(v) {
+			return v
+		}
+		return filepath.Join(dir, v)
+	case []any:
+		resolved := make([]any, len(v))
+		for i, path := range v {
+			resolved[i] = resolvePaths(path, dir)
+		}
+		return resolved
+	default:
+		return value
+	}
+}
@@ -25,7 +25,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/lemolatoon/acutils-cli/shell"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -56,9 +59,34 @@ func Execute() {
 }
 
 func init() {
-	cobra.OnInitialize(initConfig)
+	cobra.OnInitialize(initConfig, initRunner)
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		// config validate reports the problems by itself.
+		if cmd != configValidateCmd {
+			warnConfigProblems()
+		}
+		// The config commands can fix an unknown preset, and judge resolves its argument by itself.
+		if cmd == configCmd || cmd.Parent() == configCmd || cmd == judgeCmd {
+			return nil
+		}
+		if err := resolveJudgePreset(); err != nil {
+			cmd.SilenceUsage = true
+			return err
+		}
+
+		return nil
+	}
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.acutils-cli/config.toml)")
+	rootCmd.PersistentFlags().String("judge", "", "judge preset to replicate the compiler environment of (overrides JUDGE_PRESET)")
+	cobra.CheckErr(viper.BindPFlag(JUDGE_PRESET_KEY, rootCmd.PersistentFlags().Lookup("judge")))
+	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "print the external commands (compiler, solution, clipboard, editor) instead of running them")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "do not print the commands and the config files used")
+	rootCmd.PersistentFlags().BoolVar(&verbose, "verbose", false, "also print the commands probing the environment and why compilation is skipped")
+	rootCmd.MarkFlagsMutuallyExclusive("quiet", "verbose")
+	rootCmd.PersistentFlags().BoolVar(&noSandbox, "no-sandbox", false, "run solutions without the resource limits (overrides SANDBOX)")
+	rootCmd.PersistentFlags().Bool("strict", false, "fail instead of falling back when template/settings files are missing (overrides STRICT)")
+	cobra.CheckErr(viper.BindPFlag(STRICT_KEY, rootCmd.PersistentFlags().Lookup("strict")))
 }
 
 const TEMPLATE_FILE_KEY = "TEMPLATE_FILE"
@@ -89,58 +117,143 @@ func defaultTemplatePath() string {
 	return filepath.Join(home, ".acutils-cli", "template.cpp")
 }
 
-func GetTemplateFileContent() string {
+// templateSetsDir is the directory which has the named template directories for --template-set.
+func templateSetsDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return ""
+	}
+
+	return filepath.Join(home, ".acutils-cli", "templates")
+}
+
+// resolveConfigPath resolves a path in config.toml relative to the directory of config.toml.
+func resolveConfigPath(path string) string {
+	if !filepath.IsAbs(path) {
+		if dir := configDir(); dir != "" {
+			return filepath.Join(dir, path)
+		}
+	}
+
+	return path
+}
+
+const STRICT_KEY = "STRICT"
+
+// IsStrict reports whether missing template and settings files are errors
+// instead of falling back to the built-in defaults.
+func IsStrict() bool {
+	return viper.GetBool(STRICT_KEY)
+}
+
+// GetTemplateFileContent returns the content of TEMPLATE_FILE. If it cannot be read,
+// the default template is used, or an error is returned in the strict mode.
+func GetTemplateFileContent() (string, error) {
 	templateFilepath := viper.GetString(TEMPLATE_FILE_KEY)
 	if templateFilepath == "" {
 		if defaultPath := defaultTemplatePath(); defaultPath != "" {
 			if content, err := os.ReadFile(defaultPath); err == nil {
-				return string(content)
+				return string(content), nil
 			}
 		}
-		return TEMPLATE_DEFAULT
-	}
-	templateFullpath := templateFilepath
-	if !filepath.IsAbs(templateFilepath) {
-		if dir := configDir(); dir != "" {
-			templateFullpath = filepath.Join(dir, templateFilepath)
-		}
+		return TEMPLATE_DEFAULT, nil
 	}
+	templateFullpath := resolveConfigPath(templateFilepath)
 
 	content, err := os.ReadFile(templateFullpath)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to read template file: %s\n", templateFullpath)
+		if IsStrict() {
+			return "", fmt.Errorf("failed to read %s: %w", TEMPLATE_FILE_KEY, err)
+		}
+		fmt.Fprintf(os.Stderr, "Failed to read template file: %s (using the default template)\n", templateFullpath)
 		if defaultPath := defaultTemplatePath(); defaultPath != "" {
 			if content, err := os.ReadFile(defaultPath); err == nil {
-				return string(content)
+				return string(content), nil
 			}
 		}
-		return TEMPLATE_DEFAULT
+		return TEMPLATE_DEFAULT, nil
 	}
-	return string(content)
+	return string(content), nil
 }
 
+const CXX_KEY = "CXX"
+
 func GetCXX() string {
-	cxx := viper.GetString("CXX")
+	cxx := viper.GetString(CXX_KEY)
 	if cxx != "" {
 		return cxx
 	}
-	cxx = os.Getenv("CXX")
+	cxx = os.Getenv(CXX_KEY)
 	if cxx != "" {
 		return cxx
 	}
+	if preset := currentJudgePreset(); preset != nil {
+		return preset.CXX
+	}
 
 	return "c++"
 }
 
+// compilerArgv returns the argv running the compiler with the arguments. CXX may have
+// arguments of its own, e.g. "ccache g++" or "g++-13 -fdiagnostics-color", which are
+// split at spaces like $EDITOR.
+func compilerArgv(cxx string, args ...string) []string {
+	argv := strings.Fields(cxx)
+	if len(argv) == 0 {
+		argv = []string{"c++"}
+	}
+
+	return append(argv, args...)
+}
+
+const CXXFLAGS_KEY = "CXXFLAGS"
+
 var DEFAULT_CXXFLAGS = []string{"-g", "-Wall", "-Wextra", "-fsanitize=undefined,address", "-std=c++23"}
 
+// GetCXXFLAGS returns the compiler flags with the include paths, without the link flags,
+// so that they can also be written to compile_flags.txt.
 func GetCXXFLAGS() []string {
-	cxxflags := viper.GetStringSlice("CXXFLAGS")
-	if len(cxxflags) != 0 {
-		return cxxflags
+	preset := currentJudgePreset()
+	var cxxflags []string
+	if configured := viper.GetStringSlice(CXXFLAGS_KEY); len(configured) != 0 {
+		cxxflags = append(cxxflags, configured...)
+	} else if preset != nil {
+		cxxflags = append(cxxflags, preset.CXXFLAGS...)
+	} else {
+		cxxflags = append(cxxflags, DEFAULT_CXXFLAGS...)
+	}
+
+	for _, path := range includePaths(preset) {
+		cxxflags = append(cxxflags, "-I"+path)
 	}
 
-	return DEFAULT_CXXFLAGS
+	return cxxflags
+}
+
+// GetLinkFlags returns the link flags of the judge preset (e.g. -lgmp), which are passed
+// only when linking. CXXFLAGS replaces them along with the flags of the preset.
+func GetLinkFlags() []string {
+	if len(viper.GetStringSlice(CXXFLAGS_KEY)) != 0 {
+		return nil
+	}
+	if preset := currentJudgePreset(); preset != nil {
+		return preset.LinkFlags
+	}
+
+	return nil
+}
+
+const TIME_LIMIT_KEY = "TIME_LIMIT"
+const TIME_LIMIT_DEFAULT = 2 * time.Second
+
+// GetTimeLimit returns TIME_LIMIT (e.g. "2s", "500ms"), which is used to judge TLE.
+func GetTimeLimit() time.Duration {
+	timeLimit := viper.GetDuration(TIME_LIMIT_KEY)
+	if timeLimit > 0 {
+		return timeLimit
+	}
+
+	return TIME_LIMIT_DEFAULT
 }
 
 const VSCODE_TEMPLATE_SETTINGS_FILE_KEY = "VSCODE_TEMPLATE_SETTINGS_FILE"
@@ -163,19 +276,58 @@ const VSCODE_TEMPLATE_SETTINGS_DEFAULT = `
 }
 `
 
-func GetVscodeSettingsFileContent() string {
+// GetVscodeSettingsFileContent returns the content of VSCODE_TEMPLATE_SETTINGS_FILE. If it cannot
+// be read, the default settings are used, or an error is returned in the strict mode.
+func GetVscodeSettingsFileContent() (string, error) {
 	settingsJsonPath := viper.GetString(VSCODE_TEMPLATE_SETTINGS_FILE_KEY)
 	if settingsJsonPath == "" {
-		return VSCODE_TEMPLATE_SETTINGS_DEFAULT
+		return VSCODE_TEMPLATE_SETTINGS_DEFAULT, nil
 	}
-	settingsJsonFullpath := filepath.Join(configDir(), settingsJsonPath)
+	settingsJsonFullpath := resolveConfigPath(settingsJsonPath)
 
 	content, err := os.ReadFile(settingsJsonFullpath)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to read settings.json file: %s\n", settingsJsonFullpath)
-		return VSCODE_TEMPLATE_SETTINGS_DEFAULT
+		if IsStrict() {
+			return "", fmt.Errorf("failed to read %s: %w", VSCODE_TEMPLATE_SETTINGS_FILE_KEY, err)
+		}
+		fmt.Fprintf(os.Stderr, "Failed to read settings.json file: %s (using the default settings)\n", settingsJsonFullpath)
+		return VSCODE_TEMPLATE_SETTINGS_DEFAULT, nil
+	}
+	return string(content), nil
+}
+
+var (
+	dryRun  bool
+	quiet   bool
+	verbose bool
+)
+
+// runner runs the external commands of the commands, selected by --dry-run and --quiet.
+var runner shell.Runner = shell.ExecRunner{Trace: os.Stdout}
+
+func initRunner() {
+	switch {
+	case dryRun:
+		runner = shell.DryRunner{Out: os.Stdout}
+	case quiet:
+		runner = shell.ExecRunner{}
+	default:
+		runner = shell.ExecRunner{Trace: os.Stdout}
+	}
+}
+
+// logf prints a message about what acutils-cli does to stderr, unless --quiet is given.
+func logf(format string, args ...any) {
+	if !quiet {
+		fmt.Fprintf(os.Stderr, format, args...)
+	}
+}
+
+// verbosef prints a message to stderr only with --verbose.
+func verbosef(format string, args ...any) {
+	if verbose {
+		fmt.Fprintf(os.Stderr, format, args...)
 	}
-	return string(content)
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -197,7 +349,16 @@ func initConfig() {
 	viper.AutomaticEnv() // read in environment variables that match
 	// If a config file is found, read it in.
 	if err := viper.ReadInConfig(); err == nil {
-		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
+		logf("Using config file: %s\n", viper.ConfigFileUsed())
 	}
 
+	if wd, err := os.Getwd(); err == nil {
+		if path := findProjectConfig(wd); path != "" {
+			if err := mergeProjectConfig(path); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to read project config file %s: %v\n", path, err)
+			} else {
+				logf("Using project config file: %s\n", path)
+			}
+		}
+	}
 }
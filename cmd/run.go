@@ -22,12 +22,21 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 
+	"github.com/lemolatoon/acutils-cli/diagnostic"
+	"github.com/lemolatoon/acutils-cli/history"
+	"github.com/lemolatoon/acutils-cli/judge"
+	"github.com/lemolatoon/acutils-cli/sandbox"
+	"github.com/lemolatoon/acutils-cli/sanitizer"
 	"github.com/lemolatoon/acutils-cli/shell"
 	"github.com/spf13/cobra"
 )
@@ -40,38 +49,263 @@ var runCmd = &cobra.Command{
 
 Use c++ command for compiling by default. With CXX global variable, it is used as compiler.
 With CXXFLAGS in .acutils-cli.toml, you can specify compiler flags.
+With JUDGE_PRESET (or --judge), the compiler and flags of the judge are used,
+and a warning is printed when the local compiler version differs from the judge's one.
+
+When the compilation fails, only the first error of each location is shown
+with its source line. The full log is kept in compile.log in the problem directory.
+
+When AddressSanitizer or UndefinedBehaviorSanitizer reports an error, a short headline
+such as "heap-buffer-overflow at main.cpp:42 in solve()" is printed after the report.
+
+The solution runs in a sandbox limiting its memory (MEMORY_LIMIT, default: 1024MiB),
+the size of the files it writes (FILE_SIZE_LIMIT, default: 256MiB) and the number of
+processes (PROCESS_LIMIT, default: 64). Use --no-sandbox to run it without the limits.
+
+The solution reads the terminal by default. Use --input, --from-clipboard or --stdin
+to pass the input from a file, the clipboard or the command line, and --output to
+save the output to a file.
 `,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if len(args) != 1 {
 			return errors.New("problem-name must be provided")
 		}
+		// Errors from here on are not about the usage.
+		cmd.SilenceUsage = true
 
 		directory := args[0]
 
-		sourceFilePath := filepath.Join(directory, "main.cpp")
+		sourceFilePath := sourcePath(directory)
+		executeFilePath := executablePath(directory)
+		if err := compileIfNeeded(directory, false); err != nil {
+			recordEvent(directory, history.Event{Kind: history.EVENT_RUN, Verdict: string(judge.CE)})
+			return err
+		}
 
-		executeFilePath := filepath.Join(directory, "a.out")
-		if checkIfShouldCompile(sourceFilePath, executeFilePath) {
-			flags := strings.Join(GetCXXFLAGS(), " ")
-			if err := shell.Run(fmt.Sprintf("%s %s %s -o %s", GetCXX(), sourceFilePath, flags, executeFilePath)); err != nil {
+		stdin, err := runInput()
+		if err != nil {
+			return err
+		}
+		stdout := io.Writer(os.Stdout)
+		if runOutputPath != "" && !dryRun {
+			outputFile, err := os.Create(runOutputPath)
+			if err != nil {
 				return err
 			}
+			defer outputFile.Close()
+			stdout = io.MultiWriter(os.Stdout, outputFile)
 		}
 
-		var executeCommand string
-		if filepath.IsAbs(executeFilePath) {
-			executeCommand = executeFilePath
-		} else {
-			executeCommand = fmt.Sprintf("./%s", executeFilePath)
+		var stderr bytes.Buffer
+		execute := shell.Command(commandPath(executeFilePath))
+		execute.Stdin = stdin
+		execute.Stdout = stdout
+		execute.Stderr = io.MultiWriter(os.Stderr, &stderr)
+		execute.Limits = sandboxLimits(0)
+		err = runner.Run(execute)
+		execution := judge.NewExecution(err, stderr.Bytes())
+		if execution.Report != nil {
+			printSanitizerReport(execution.Report, sourceFilePath, executeFilePath)
 		}
-		if err := shell.Run(executeCommand); err != nil {
-			return err
+		recordEvent(directory, history.Event{Kind: history.EVENT_RUN, Verdict: string(runVerdict(execution))})
+		if execution.Limit == sandbox.LIMIT_MEMORY {
+			return fmt.Errorf("%s: %w", judge.MLE, err)
+		}
+		if execution.RuntimeError() {
+			if err != nil {
+				return fmt.Errorf("%s: %w", judge.RE, err)
+			}
+			return fmt.Errorf("%s: reported by %s", judge.RE, execution.Report.Sanitizer)
 		}
 
 		return nil
 	},
 }
 
+// runVerdict returns the verdict of run, which has no expected output.
+func runVerdict(execution judge.Execution) judge.Verdict {
+	switch {
+	case execution.Limit == sandbox.LIMIT_MEMORY:
+		return judge.MLE
+	case execution.RuntimeError():
+		return judge.RE
+	}
+
+	return judge.OK
+}
+
+var (
+	runInputPath     string
+	runFromClipboard bool
+	runStdin         string
+	runOutputPath    string
+)
+
+// runInput returns the stdin for the solution chosen by the flags of run.
+// By default, the solution reads the terminal interactively.
+func runInput() (io.Reader, error) {
+	switch {
+	case runInputPath != "":
+		content, err := os.ReadFile(runInputPath)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(content), nil
+	case runFromClipboard:
+		content, err := readClipboard()
+		if err != nil {
+			return nil, err
+		}
+		return strings.NewReader(content), nil
+	case runStdin != "":
+		return strings.NewReader(unescapeInput(runStdin)), nil
+	default:
+		return os.Stdin, nil
+	}
+}
+
+// unescapeInput interprets \n, \t, \r and \\ so that multi-line input can be written inline.
+// A trailing newline is added as most solutions expect the input to end with one.
+func unescapeInput(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case '\\':
+			b.WriteByte('\\')
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(s[i])
+		}
+	}
+	if !strings.HasSuffix(b.String(), "\n") {
+		b.WriteByte('\n')
+	}
+
+	return b.String()
+}
+
+// printSanitizerReport prints the headline of the report, symbolizing it if needed.
+func printSanitizerReport(report *sanitizer.Report, sourceFilePath string, executeFilePath string) {
+	if err := report.Symbolize(executeFilePath); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+	fmt.Fprintf(os.Stderr, "%s: %s\n", judge.RE, report.Headline(sourceFilePath))
+	if report.Access != "" {
+		fmt.Fprintf(os.Stderr, "  %s\n", report.Access)
+	}
+	if report.Shadow != "" {
+		fmt.Fprintf(os.Stderr, "  shadow byte: %s\n", report.Shadow)
+	}
+}
+
+func sourcePath(directory string) string {
+	return filepath.Join(directory, "main.cpp")
+}
+
+func executablePath(directory string) string {
+	return filepath.Join(directory, "a.out")
+}
+
+// compileIfNeeded compiles main.cpp in the problem directory when it is newer than a.out, or when forced.
+func compileIfNeeded(directory string, force bool) error {
+	sourceFilePath := sourcePath(directory)
+	executeFilePath := executablePath(directory)
+	if !force && !checkIfShouldCompile(sourceFilePath, executeFilePath) {
+		verbosef("%s is up to date, skip compiling\n", executeFilePath)
+		return nil
+	}
+	warnJudgeCompilerMismatch()
+
+	return compile(sourceFilePath, executeFilePath)
+}
+
+// commandPath returns the path to run the executable with, which is not looked up in $PATH.
+func commandPath(executeFilePath string) string {
+	if filepath.IsAbs(executeFilePath) {
+		return executeFilePath
+	}
+
+	return "./" + filepath.Clean(executeFilePath)
+}
+
+const COMPILE_LOG_FILE = "compile.log"
+
+// compile compiles the source file and prints a condensed summary of the diagnostics,
+// keeping the full log in COMPILE_LOG_FILE next to the source file.
+func compile(sourceFilePath string, executeFilePath string) error {
+	return compileWithFlags(sourceFilePath, executeFilePath, GetCXXFLAGS())
+}
+
+// compileWithFlags is compile with the flags instead of CXXFLAGS.
+func compileWithFlags(sourceFilePath string, executeFilePath string, flags []string) error {
+	cxx := GetCXX()
+	flags = slices.Clone(flags)
+	if supportsJSONDiagnostics(cxx) {
+		flags = append(flags, diagnostic.JSONFlag)
+	}
+
+	var stderr bytes.Buffer
+	args := append([]string{sourceFilePath}, flags...)
+	args = append(args, "-o", executeFilePath)
+	// The libraries come after the source file, which uses them.
+	argv := compilerArgv(cxx, append(args, GetLinkFlags()...)...)
+	compileCmd := shell.Command(argv[0], argv[1:]...)
+	compileCmd.Stderr = &stderr
+	if dryRun {
+		return runner.Run(compileCmd)
+	}
+	compileErr := runner.Run(compileCmd)
+	diagnostics := diagnostic.Parse(stderr.Bytes())
+
+	// The log is the output as it is, including the lines the parser does not recognize.
+	logPath := filepath.Join(filepath.Dir(sourceFilePath), COMPILE_LOG_FILE)
+	if err := os.WriteFile(logPath, stderr.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	if len(diagnostics) == 0 {
+		// Nothing recognizable, so show the output as it is.
+		os.Stderr.Write(diagnostic.Text(stderr.Bytes()))
+	} else if err := diagnostic.Summarize(os.Stderr, diagnostics); err != nil {
+		return err
+	}
+
+	if compileErr != nil {
+		errorCount, warningCount := diagnostic.Count(diagnostics)
+		return fmt.Errorf("compilation failed with %d error(s) and %d warning(s), full log: %s: %w", errorCount, warningCount, logPath, compileErr)
+	}
+
+	return nil
+}
+
+var jsonDiagnosticsSupport = map[string]bool{}
+
+// supportsJSONDiagnostics reports whether the compiler accepts diagnostic.JSONFlag.
+// Clang and GCC older than 9 do not.
+func supportsJSONDiagnostics(cxx string) bool {
+	supported, ok := jsonDiagnosticsSupport[cxx]
+	if !ok {
+		argv := compilerArgv(cxx, diagnostic.JSONFlag, "-fsyntax-only", "-x", "c++", "-")
+		probe := exec.Command(argv[0], argv[1:]...)
+		verbosef("+%s < /dev/null\n", shell.Join(probe.Args))
+		supported = probe.Run() == nil
+		jsonDiagnosticsSupport[cxx] = supported
+	}
+
+	return supported
+}
+
 func checkIfShouldCompile(sourceFilePath string, executeFilePath string) bool {
 	executeFileInfo, err := os.Stat(executeFilePath)
 	if os.IsNotExist(err) {
@@ -89,4 +323,9 @@ func checkIfShouldCompile(sourceFilePath string, executeFilePath string) bool {
 
 func init() {
 	rootCmd.AddCommand(runCmd)
+	runCmd.Flags().StringVar(&runInputPath, "input", "", "file to pass to the solution as stdin")
+	runCmd.Flags().BoolVar(&runFromClipboard, "from-clipboard", false, "pass the content of the clipboard to the solution as stdin")
+	runCmd.Flags().StringVar(&runStdin, "stdin", "", `string to pass to the solution as stdin (\n is a newline)`)
+	runCmd.MarkFlagsMutuallyExclusive("input", "from-clipboard", "stdin")
+	runCmd.Flags().StringVarP(&runOutputPath, "output", "o", "", "also save the output of the solution to the file")
 }
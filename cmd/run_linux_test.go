@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const ptyRunEnv = "ACUTILS_TEST_PTY_RUN"
+
+// syncBuffer is a bytes.Buffer written by a goroutine and read by the test.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// openPTY returns the master and the slave of a new pseudo terminal.
+func openPTY(t *testing.T) (*os.File, *os.File) {
+	t.Helper()
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		t.Skipf("no pseudo terminal: %v", err)
+	}
+	if err := unix.IoctlSetPointerInt(int(master.Fd()), unix.TIOCSPTLCK, 0); err != nil {
+		master.Close()
+		t.Fatalf("failed to unlock the pseudo terminal: %v", err)
+	}
+	number, err := unix.IoctlGetInt(int(master.Fd()), unix.TIOCGPTN)
+	if err != nil {
+		master.Close()
+		t.Fatalf("failed to get the pseudo terminal number: %v", err)
+	}
+	slave, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", number), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		t.Fatalf("failed to open the pseudo terminal: %v", err)
+	}
+
+	return master, slave
+}
+
+func TestRunReadsTerminalAndIsInterrupted(t *testing.T) {
+	if directory := os.Getenv(ptyRunEnv); directory != "" {
+		// The process started below, whose controlling terminal is the pseudo terminal.
+		resetViperState(t)
+		err := runCmd.RunE(runCmd, []string{directory})
+		fmt.Printf("run returned: %v\n", err)
+		return
+	}
+
+	resetViperState(t)
+	t.Setenv("HOME", t.TempDir())
+	directory := filepath.Join(t.TempDir(), "a")
+	if err := os.MkdirAll(directory, 0o755); err != nil {
+		t.Fatalf("failed to create problem dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(directory, "main.cpp"), []byte("int main() {}\n"), 0o644); err != nil {
+		t.Fatalf("failed to write main.cpp: %v", err)
+	}
+	solution := "#!/bin/sh\nread n\necho \"answer $n\"\nexec sleep 10\n"
+	if err := os.WriteFile(filepath.Join(directory, "a.out"), []byte(solution), 0o755); err != nil {
+		t.Fatalf("failed to write a.out: %v", err)
+	}
+	// a.out is newer than main.cpp, so that it is not compiled.
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(directory, "a.out"), future, future); err != nil {
+		t.Fatalf("failed to touch a.out: %v", err)
+	}
+
+	master, slave := openPTY(t)
+	defer master.Close()
+	child := exec.Command(os.Args[0], "-test.run=^TestRunReadsTerminalAndIsInterrupted$")
+	child.Env = append(os.Environ(), ptyRunEnv+"="+directory)
+	child.Stdin, child.Stdout, child.Stderr = slave, slave, slave
+	child.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true, Ctty: 0}
+	if err := child.Start(); err != nil {
+		slave.Close()
+		t.Fatalf("failed to start run on the pseudo terminal: %v", err)
+	}
+	slave.Close()
+	done := make(chan error, 1)
+	go func() { done <- child.Wait() }()
+	var output syncBuffer
+	go func() {
+		chunk := make([]byte, 4096)
+		for {
+			n, err := master.Read(chunk)
+			_, _ = output.Write(chunk[:n])
+			if err != nil {
+				// The master fails with EIO after the last slave is closed.
+				return
+			}
+		}
+	}()
+
+	waitFor := func(want string) {
+		t.Helper()
+		deadline := time.Now().Add(10 * time.Second)
+		for !strings.Contains(output.String(), want) {
+			if time.Now().After(deadline) {
+				_ = child.Process.Kill()
+				t.Fatalf("timed out waiting for %q:\n%s", want, output.String())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// The solution reads the terminal instead of being stopped by SIGTTIN.
+	if _, err := master.Write([]byte("3\n")); err != nil {
+		t.Fatalf("failed to write to the pseudo terminal: %v", err)
+	}
+	waitFor("answer 3")
+	// Ctrl-C interrupts the solution, and run reports it.
+	if _, err := master.Write([]byte{0x03}); err != nil {
+		t.Fatalf("failed to write to the pseudo terminal: %v", err)
+	}
+	waitFor("run returned: RE")
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("run on the pseudo terminal failed: %v\n%s", err, output.String())
+		}
+	case <-time.After(10 * time.Second):
+		_ = child.Process.Kill()
+		t.Fatalf("run did not finish:\n%s", output.String())
+	}
+}
@@ -0,0 +1,132 @@
+/*
+Copyright © 2024 lemolatoon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lemolatoon/acutils-cli/sandbox"
+	"github.com/spf13/viper"
+)
+
+const SANDBOX_KEY = "SANDBOX"
+const SANDBOX_TEMP_DIR_KEY = "SANDBOX_TEMP_DIR"
+const MEMORY_LIMIT_KEY = "MEMORY_LIMIT"
+const MEMORY_LIMIT_DEFAULT = 1024 << 20
+const FILE_SIZE_LIMIT_KEY = "FILE_SIZE_LIMIT"
+const FILE_SIZE_LIMIT_DEFAULT = 256 << 20
+const PROCESS_LIMIT_KEY = "PROCESS_LIMIT"
+const PROCESS_LIMIT_DEFAULT = 64
+
+var noSandbox bool
+
+// IsSandboxed reports whether solutions are run with the resource limits.
+// SANDBOX is true by default, and --no-sandbox overrides it.
+func IsSandboxed() bool {
+	if noSandbox {
+		return false
+	}
+	if viper.IsSet(SANDBOX_KEY) {
+		return viper.GetBool(SANDBOX_KEY)
+	}
+
+	return true
+}
+
+// getSize returns the size of the key (e.g. "1024MB"), or the default if it is not set or invalid.
+func getSize(key string, defaultSize int64) int64 {
+	value := viper.GetString(key)
+	if value == "" {
+		return defaultSize
+	}
+	size, err := sandbox.ParseSize(value)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v (using %s)\n", key, err, sandbox.FormatSize(defaultSize))
+		return defaultSize
+	}
+
+	return size
+}
+
+// GetMemoryLimit returns MEMORY_LIMIT in bytes, which limits the address space of solutions.
+func GetMemoryLimit() int64 {
+	return getSize(MEMORY_LIMIT_KEY, MEMORY_LIMIT_DEFAULT)
+}
+
+// GetFileSizeLimit returns FILE_SIZE_LIMIT in bytes, which limits each file written by solutions.
+func GetFileSizeLimit() int64 {
+	return getSize(FILE_SIZE_LIMIT_KEY, FILE_SIZE_LIMIT_DEFAULT)
+}
+
+// GetProcessLimit returns PROCESS_LIMIT, the number of processes and threads solutions may create.
+func GetProcessLimit() int {
+	if limit := viper.GetInt(PROCESS_LIMIT_KEY); limit > 0 {
+		return limit
+	}
+
+	return PROCESS_LIMIT_DEFAULT
+}
+
+// sandboxLimits returns the limits to run solutions with, or nil without the sandbox.
+// The CPU time is limited only if cpuTime is positive, as run may wait for the terminal.
+func sandboxLimits(cpuTime time.Duration) *sandbox.Limits {
+	if !IsSandboxed() {
+		return nil
+	}
+	if !sandbox.Supported {
+		verbosef("resource limits are not supported on this system, only the process group is used\n")
+	}
+
+	limits := &sandbox.Limits{
+		CPUTime:   cpuTime,
+		Memory:    GetMemoryLimit(),
+		FileSize:  GetFileSizeLimit(),
+		Processes: GetProcessLimit(),
+		TempDir:   viper.GetBool(SANDBOX_TEMP_DIR_KEY),
+	}
+	if usesAddressSanitizer(GetCXXFLAGS()) {
+		verbosef("%s is not applied, as AddressSanitizer reserves a huge address space\n", MEMORY_LIMIT_KEY)
+		limits.Memory = 0
+	}
+
+	return limits
+}
+
+// usesAddressSanitizer reports whether the flags enable AddressSanitizer, e.g. -fsanitize=undefined,address.
+func usesAddressSanitizer(flags []string) bool {
+	for _, flag := range flags {
+		sanitizers, ok := strings.CutPrefix(flag, "-fsanitize=")
+		if !ok {
+			continue
+		}
+		for _, name := range strings.Split(sanitizers, ",") {
+			if name == "address" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
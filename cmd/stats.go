@@ -0,0 +1,119 @@
+/*
+Copyright © 2024 lemolatoon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lemolatoon/acutils-cli/history"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const HISTORY_KEY = "HISTORY"
+
+// IsHistoryEnabled returns HISTORY, whether the events are logged in the contest directory.
+func IsHistoryEnabled() bool {
+	if viper.IsSet(HISTORY_KEY) {
+		return viper.GetBool(HISTORY_KEY)
+	}
+
+	return true
+}
+
+// recordEvent logs the event of the problem in the contest directory, which is the parent
+// of the problem directory. Failing to log only prints a warning, so that it never fails
+// the command.
+func recordEvent(directory string, event history.Event) {
+	if dryRun || !IsHistoryEnabled() {
+		return
+	}
+	abs, err := filepath.Abs(directory)
+	if err == nil {
+		event.Time = time.Now()
+		event.Problem = filepath.Base(abs)
+		err = history.Append(filepath.Dir(abs), event)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: cannot record the history: %v\n", err)
+	}
+}
+
+var statsCmd = &cobra.Command{
+	Use:   "stats [contest-name]",
+	Short: "Show the time to solve each problem of the contest and the numbers of tries.",
+	Long: `Show the time to solve each problem of the contest and the numbers of tries.
+
+new, run, test, clip and submitted record their events in .acutils-history.jsonl
+in the contest directory (the parent of the problem directory), one JSON object per
+line, unless HISTORY is false in config.toml. For each problem, stats shows when it
+was started, the time to the first AC, the numbers of tests, runs and clips, and the
+number of submissions which were not accepted (WA, TLE, RE, ... but not CE).
+
+The first AC is the first accepted submission recorded with "acutils-cli submitted".
+Without one, the first test in which all the cases passed is used, marked with "*".
+The contest directory is the current directory by default.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		directory := "."
+		if len(args) == 1 {
+			directory = args[0]
+		}
+
+		events, err := history.Load(directory)
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("no history in %s; it is recorded by new, run, test, clip and submitted", history.Path(directory))
+		}
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%-12s%-18s%10s%7s%6s%7s%5s\n", "problem", "started", "first AC", "tests", "runs", "clips", "WA")
+		local := false
+		for _, s := range history.Summarize(events) {
+			firstAC := "-"
+			if !s.FirstAC.IsZero() {
+				firstAC = s.TimeToFirstAC().Round(time.Second).String()
+				if s.LocalAC {
+					firstAC += "*"
+					local = true
+				}
+			}
+			fmt.Printf("%-12s%-18s%10s%7d%6d%7d%5d\n", s.Problem, s.Started.Local().Format("2006-01-02 15:04"), firstAC, s.Tests, s.Runs, s.Clips, s.Rejected)
+		}
+		if local {
+			fmt.Println("* the first test in which all the cases passed, as no accepted submission is recorded")
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+}
@@ -0,0 +1,79 @@
+/*
+Copyright © 2024 lemolatoon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/lemolatoon/acutils-cli/history"
+	"github.com/lemolatoon/acutils-cli/judge"
+	"github.com/spf13/cobra"
+)
+
+// SUBMISSION_VERDICTS are the verdicts of the judge which submitted accepts.
+var SUBMISSION_VERDICTS = []judge.Verdict{judge.AC, judge.WA, judge.RE, judge.TLE, judge.MLE, judge.CE}
+
+var submittedCmd = &cobra.Command{
+	Use:   "submitted problem-name verdict",
+	Short: "Record the verdict of the judge for a submission of the problem.",
+	Long: `Record the verdict of the judge for a submission of the problem.
+
+The submission is recorded in the history of the contest, which "acutils-cli stats"
+summarizes, e.g. after submitting the code copied by clip:
+
+  acutils-cli submitted a WA`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		directory := args[0]
+		verdict := judge.Verdict(strings.ToUpper(args[1]))
+		if !slices.Contains(SUBMISSION_VERDICTS, verdict) {
+			return fmt.Errorf("unknown verdict %q (available: %s)", args[1], submissionVerdictNames())
+		}
+		cmd.SilenceUsage = true
+		if _, err := os.Stat(directory); err != nil {
+			return err
+		}
+		if !IsHistoryEnabled() {
+			return fmt.Errorf("the history is disabled by %s in config.toml", HISTORY_KEY)
+		}
+
+		recordEvent(directory, history.Event{Kind: history.EVENT_SUBMIT, Verdict: string(verdict)})
+
+		return nil
+	},
+}
+
+func submissionVerdictNames() string {
+	names := make([]string, len(SUBMISSION_VERDICTS))
+	for i, verdict := range SUBMISSION_VERDICTS {
+		names[i] = string(verdict)
+	}
+
+	return strings.Join(names, ", ")
+}
+
+func init() {
+	rootCmd.AddCommand(submittedCmd)
+}
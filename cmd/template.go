@@ -0,0 +1,329 @@
+/*
+Copyright © 2024 lemolatoon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const AUTHOR_KEY = "AUTHOR"
+
+// TEMPLATE_SUFFIX marks the template files rendered with text/template, e.g. main.cpp.tmpl,
+// which is written without the suffix. Other files are copied as they are, as C++ such as
+// "vector<pair<int, int>> d = {{0, 1}, {1, 0}};" is not a valid text/template.
+const TEMPLATE_SUFFIX = ".tmpl"
+const TEMPLATE_VARS_KEY = "TEMPLATE_VARS"
+
+// TemplateData is the data available in templates, e.g. {{.Contest}} or {{.Vars.name}}.
+type TemplateData struct {
+	Contest   string
+	Problem   string
+	URL       string
+	Date      string
+	Author    string
+	TimeLimit string
+	// Vars are the values of the TEMPLATE_VARS table in config.toml.
+	// The keys are lower case, as config keys are case-insensitive.
+	Vars map[string]any
+}
+
+// newTemplateData returns the data for the problem directory.
+// The contest name is the name of the directory containing the problem directory.
+func newTemplateData(problemDirectory string) TemplateData {
+	problem := filepath.Base(problemDirectory)
+	contest := ""
+	if abs, err := filepath.Abs(problemDirectory); err == nil {
+		contest = filepath.Base(filepath.Dir(abs))
+	}
+
+	author := viper.GetString(AUTHOR_KEY)
+	if author == "" {
+		if current, err := user.Current(); err == nil {
+			author = current.Username
+		}
+	}
+
+	vars := viper.GetStringMap(TEMPLATE_VARS_KEY)
+	if vars == nil {
+		vars = map[string]any{}
+	}
+
+	return TemplateData{
+		Contest:   contest,
+		Problem:   problem,
+		URL:       atcoderTaskURL(contest, problem),
+		Date:      time.Now().Format("2006-01-02"),
+		Author:    author,
+		TimeLimit: GetTimeLimit().String(),
+		Vars:      vars,
+	}
+}
+
+func atcoderTaskURL(contest string, problem string) string {
+	taskID := strings.ReplaceAll(strings.ToLower(contest), "-", "_") + "_" + strings.ToLower(problem)
+	return fmt.Sprintf("https://atcoder.jp/contests/%s/tasks/%s", strings.ToLower(contest), taskID)
+}
+
+var templateErrorLinePattern = regexp.MustCompile(`^template: [^:]*:(\d+)`)
+
+// RenderTemplate renders the template source with text/template.
+// On failure, the error points at the line of the template.
+func RenderTemplate(name string, content string, data TemplateData) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(content)
+	if err == nil {
+		var rendered bytes.Buffer
+		if err = tmpl.Execute(&rendered, data); err == nil {
+			return rendered.String(), nil
+		}
+	}
+
+	match := templateErrorLinePattern.FindStringSubmatch(err.Error())
+	if match == nil {
+		return "", fmt.Errorf("failed to render template %s: %w", name, err)
+	}
+	line, _ := strconv.Atoi(match[1])
+	lines := strings.Split(content, "\n")
+	if line < 1 || line > len(lines) {
+		return "", fmt.Errorf("failed to render template %s: %w", name, err)
+	}
+
+	return "", fmt.Errorf("failed to render template %s at line %d: %w\n %d | %s", name, line, err, line, lines[line-1])
+}
+
+// TemplateFile is a file to put in a new problem directory.
+type TemplateFile struct {
+	// Path is relative to the problem directory.
+	Path    string
+	Content []byte
+	Mode    fs.FileMode
+	// Source is where the file comes from, used in error messages.
+	Source string
+	// Render is true for the files with TEMPLATE_SUFFIX, which are rendered with text/template.
+	Render bool
+}
+
+// loadTemplate loads a template file, which becomes main.cpp, or a template directory,
+// whose files are copied recursively.
+func loadTemplate(path string) ([]TemplateFile, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template %s: %w", path, err)
+	}
+	if !info.IsDir() {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template file %s: %w", path, err)
+		}
+		return []TemplateFile{{Path: "main.cpp", Content: content, Mode: info.Mode().Perm(), Source: path, Render: strings.HasSuffix(path, TEMPLATE_SUFFIX)}}, nil
+	}
+
+	var files []TemplateFile
+	err = filepath.WalkDir(path, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(path, filePath)
+		if err != nil {
+			return err
+		}
+		render := strings.HasSuffix(relative, TEMPLATE_SUFFIX)
+		files = append(files, TemplateFile{Path: strings.TrimSuffix(relative, TEMPLATE_SUFFIX), Content: content, Mode: info.Mode().Perm(), Source: filePath, Render: render})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template directory %s: %w", path, err)
+	}
+
+	return files, nil
+}
+
+// loadTemplateSet loads the template directory with the name in templateSetsDir.
+func loadTemplateSet(name string) ([]TemplateFile, error) {
+	dir := templateSetsDir()
+	if info, err := os.Stat(filepath.Join(dir, name)); err != nil || !info.IsDir() {
+		var names []string
+		entries, _ := os.ReadDir(dir)
+		for _, entry := range entries {
+			if entry.IsDir() {
+				names = append(names, entry.Name())
+			}
+		}
+		return nil, fmt.Errorf("template set %q is not found in %s (available: %s)", name, dir, strings.Join(names, ", "))
+	}
+
+	return loadTemplate(filepath.Join(dir, name))
+}
+
+const TEMPLATE_RULES_KEY = "TEMPLATE_RULES"
+
+// TemplateRule selects a template for problems matching Pattern.
+//
+//	[[TEMPLATE_RULES]]
+//	pattern = "abc*/[a-b]"
+//	template = "minimal.cpp"
+//
+//	[[TEMPLATE_RULES]]
+//	pattern = "agc*/*"
+//	template_set = "heavy"
+type TemplateRule struct {
+	// Pattern is matched against "contest/problem", or against "problem" if it has no slash,
+	// with the syntax of path.Match, ignoring case.
+	Pattern string `mapstructure:"pattern"`
+	// Template is a template file or directory, relative to the directory of the config file
+	// declaring the rule.
+	Template string `mapstructure:"template"`
+	// TemplateSet is the name of a template directory in $HOME/.acutils-cli/templates.
+	TemplateSet string `mapstructure:"template_set"`
+}
+
+func (r TemplateRule) Match(contest string, problem string) (bool, error) {
+	name := strings.ToLower(problem)
+	if strings.Contains(r.Pattern, "/") {
+		name = strings.ToLower(contest) + "/" + name
+	}
+
+	matched, err := path.Match(strings.ToLower(r.Pattern), name)
+	if err != nil {
+		return false, fmt.Errorf("invalid pattern %q in %s: %w", r.Pattern, TEMPLATE_RULES_KEY, err)
+	}
+
+	return matched, nil
+}
+
+// GetTemplateRules returns TEMPLATE_RULES in config.toml.
+func GetTemplateRules() ([]TemplateRule, error) {
+	var rules []TemplateRule
+	if err := viper.UnmarshalKey(TEMPLATE_RULES_KEY, &rules); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", TEMPLATE_RULES_KEY, err)
+	}
+
+	return rules, nil
+}
+
+// GetTemplateFiles returns the files of the template for a new problem.
+// The first rule in TEMPLATE_RULES matching the problem selects the template.
+// Otherwise TEMPLATE_FILE, which may be a directory, is used. Without it, the "default"
+// template set is used if it exists, and otherwise the template file returned by
+// GetTemplateFileContent.
+func GetTemplateFiles(contest string, problem string) ([]TemplateFile, error) {
+	rules, err := GetTemplateRules()
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range rules {
+		matched, err := rule.Match(contest, problem)
+		if err != nil {
+			return nil, err
+		}
+		if !matched {
+			continue
+		}
+		switch {
+		case rule.TemplateSet != "":
+			return loadTemplateSet(rule.TemplateSet)
+		case rule.Template != "":
+			return loadTemplate(resolveConfigPath(rule.Template))
+		default:
+			return nil, fmt.Errorf("rule %q in %s has neither template nor template_set", rule.Pattern, TEMPLATE_RULES_KEY)
+		}
+	}
+
+	if configured := viper.GetString(TEMPLATE_FILE_KEY); configured != "" {
+		if _, err := os.Stat(resolveConfigPath(configured)); err == nil {
+			return loadTemplate(resolveConfigPath(configured))
+		}
+	} else if dir := templateSetsDir(); dir != "" {
+		if info, err := os.Stat(filepath.Join(dir, "default")); err == nil && info.IsDir() {
+			return loadTemplate(filepath.Join(dir, "default"))
+		}
+		if _, err := os.Stat(defaultTemplatePath() + TEMPLATE_SUFFIX); err == nil {
+			return loadTemplate(defaultTemplatePath() + TEMPLATE_SUFFIX)
+		}
+	}
+
+	content, err := GetTemplateFileContent()
+	if err != nil {
+		return nil, err
+	}
+
+	return []TemplateFile{{Path: "main.cpp", Content: []byte(content), Mode: 0644, Source: "template.cpp"}}, nil
+}
+
+// writeTemplateFiles renders the template files with TEMPLATE_SUFFIX and writes all the files
+// into the problem directory, keeping their file modes.
+func writeTemplateFiles(directory string, files []TemplateFile, data TemplateData) error {
+	rendered := make([][]byte, len(files))
+	for i, file := range files {
+		if !file.Render {
+			rendered[i] = file.Content
+			continue
+		}
+		content, err := RenderTemplate(file.Source, string(file.Content), data)
+		if err != nil {
+			return err
+		}
+		rendered[i] = []byte(content)
+	}
+
+	if err := os.Mkdir(directory, 0755); err != nil {
+		return err
+	}
+	for i, file := range files {
+		path := filepath.Join(directory, file.Path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, rendered[i], file.Mode); err != nil {
+			return err
+		}
+		// WriteFile is affected by umask.
+		if err := os.Chmod(path, file.Mode); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
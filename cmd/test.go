@@ -0,0 +1,393 @@
+/*
+Copyright © 2024 lemolatoon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lemolatoon/acutils-cli/diff"
+	"github.com/lemolatoon/acutils-cli/history"
+	"github.com/lemolatoon/acutils-cli/judge"
+	"github.com/lemolatoon/acutils-cli/sandbox"
+	"github.com/lemolatoon/acutils-cli/shell"
+	"github.com/spf13/cobra"
+)
+
+// testCmd represents the test command
+var testCmd = &cobra.Command{
+	Use:   "test problem-name",
+	Short: "Compile and test the solution against the test cases of the problem.",
+	Long: `Compile and test the solution against the test cases of the problem.
+
+Test cases are pairs of tests/NAME.in and tests/NAME.out in the problem directory.
+The output is compared token by token, ignoring differences in whitespace.
+On WA, a diff of the lines around the first differing token is shown, colored
+on a terminal unless NO_COLOR is set.
+Cases without NAME.out are only checked for runtime errors.
+TLE is judged with TIME_LIMIT in config.toml (default: 2s), which also limits the CPU time.
+The solution runs in the sandbox described in "acutils-cli run --help", and exceeding
+MEMORY_LIMIT is judged as MLE.
+
+The cases run in parallel on --jobs workers (default: the number of CPUs), and the
+results are printed in the order of the cases. Cases judged as TLE in parallel are
+measured again alone; use --serial to run every case alone.
+
+With --split, the input of a failed case with "T test cases" on its first line is split
+into its cases, which are run one by one to find the first failing one. The outputs are
+compared with the reference solution (--naive, default: naive.cpp in the problem
+directory) or else with the expected output. The format of the cases is given as
+"lines:K" for K lines each, or "header:I" for a header line followed by as many lines
+as its I-th number (e.g. "header:2" for "N M" followed by M lines).
+
+With --format json or --format junit, the results are printed to stdout as a JSON
+object or a JUnit XML test suite (with the verdict, time, memory and the details of
+failed cases) for CI, and the commands are traced to stderr. --split and --backtrace
+print their diagnoses as text, so they cannot be used with them.
+
+With --backtrace, the cases judged as RE are run again in gdb or lldb, and the signal
+and the backtrace at which they stopped are printed.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("problem-name must be provided")
+		}
+		format := judge.Format(testFormat)
+		if !slices.Contains(judge.FORMATS, format) {
+			return fmt.Errorf("unknown format %q (available: %s)", testFormat, formatNames())
+		}
+		if format != judge.FORMAT_TEXT && testSplit != "" {
+			return fmt.Errorf("--split cannot be used with --format %s", format)
+		}
+		if format != judge.FORMAT_TEXT && testBacktrace {
+			return fmt.Errorf("--backtrace cannot be used with --format %s", format)
+		}
+		cmd.SilenceUsage = true
+		if format != judge.FORMAT_TEXT {
+			// Keep stdout for the results only.
+			if execRunner, ok := runner.(shell.ExecRunner); ok && execRunner.Trace != nil {
+				runner = shell.ExecRunner{Trace: os.Stderr}
+			}
+		}
+
+		var splitter *judge.Splitter
+		if testSplit != "" {
+			parsed, err := judge.ParseSplitter(testSplit)
+			if err != nil {
+				return err
+			}
+			splitter = &parsed
+		}
+
+		directory := args[0]
+		if err := compileIfNeeded(directory, false); err != nil {
+			recordEvent(directory, history.Event{Kind: history.EVENT_TEST, Verdict: string(judge.CE)})
+			return err
+		}
+		if dryRun {
+			return dryRunTests(directory)
+		}
+		var report func(judge.Result)
+		if format == judge.FORMAT_TEXT {
+			diffOptions := diff.DefaultOptions
+			diffOptions.Color = colorEnabled(os.Stdout)
+			report = func(result judge.Result) {
+				printResult(os.Stdout, directory, result, diffOptions)
+			}
+		}
+		results, err := runTests(directory, report)
+		if err != nil {
+			return err
+		}
+
+		summary := judge.Summarize(results)
+		recordEvent(directory, testEvent(summary))
+		switch format {
+		case judge.FORMAT_JSON:
+			symbolizeReports(directory, results)
+			err = judge.WriteJSON(os.Stdout, directory, sourcePath(directory), results)
+		case judge.FORMAT_JUNIT:
+			symbolizeReports(directory, results)
+			err = judge.WriteJUnit(os.Stdout, directory, sourcePath(directory), results)
+		default:
+			if splitter != nil {
+				diagnoseSubcases(directory, results, *splitter)
+			}
+			if testBacktrace {
+				printBacktraces(directory, results)
+			}
+			fmt.Println(summaryLine(results))
+		}
+		if err != nil {
+			return err
+		}
+		if !summary.Passed() {
+			return fmt.Errorf("%s", summary.Verdict())
+		}
+
+		return nil
+	},
+}
+
+var (
+	testJobs      int
+	testSerial    bool
+	testFormat    string
+	testSplit     string
+	testNaive     string
+	testBacktrace bool
+)
+
+const NAIVE_SOURCE_FILE = "naive.cpp"
+
+// naiveExecutable compiles the reference solution given by --naive, or naive.cpp in the
+// problem directory if it exists, and returns its executable. It returns "" if there is none.
+func naiveExecutable(directory string) (string, error) {
+	source := testNaive
+	if source == "" {
+		source = filepath.Join(directory, NAIVE_SOURCE_FILE)
+		if _, err := os.Stat(source); err != nil {
+			return "", nil
+		}
+	}
+	executable, err := compileSolution(directory, source)
+	if err != nil {
+		return "", fmt.Errorf("failed to compile the reference solution: %w", err)
+	}
+
+	return executable, nil
+}
+
+// diagnoseSubcases splits the inputs of the failed cases into their test cases and prints
+// the first failing one of each.
+func diagnoseSubcases(directory string, results []judge.Result, splitter judge.Splitter) {
+	var failed []judge.Result
+	for _, result := range results {
+		if result.Verdict == judge.WA || result.Verdict == judge.RE {
+			failed = append(failed, result)
+		}
+	}
+	if len(failed) == 0 {
+		return
+	}
+
+	// The reference solution is compiled only when there is a case to split.
+	reference, err := naiveExecutable(directory)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+	dir, err := os.MkdirTemp("", "acutils-split-")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	var limits sandbox.Limits
+	if sandboxed := sandboxLimits(GetTimeLimit()); sandboxed != nil {
+		limits = *sandboxed
+	}
+	opts := judge.Options{TimeLimit: GetTimeLimit(), Limits: limits}
+	diffOptions := diff.DefaultOptions
+	diffOptions.Color = colorEnabled(os.Stdout)
+	for _, result := range failed {
+		fmt.Printf("%s split into its cases:\n", result.Case.Name)
+		report, err := judge.RunSubcases(commandPath(executablePath(directory)), reference, result, splitter, opts, dir)
+		if err != nil {
+			fmt.Printf("  cannot split: %v\n", err)
+			continue
+		}
+		if report.PassesAlone() {
+			fmt.Printf("  all %d cases pass when run alone; is a global state reset between the cases?\n", report.Count)
+			continue
+		}
+		fmt.Printf("  case %d of %d fails alone:\n", report.Index, report.Count)
+		fmt.Printf("  input:\n%s", indent(report.Input()))
+		var b strings.Builder
+		printResult(&b, directory, report.Result, diffOptions)
+		fmt.Print(indent([]byte(b.String())))
+	}
+}
+
+func formatNames() string {
+	names := make([]string, len(judge.FORMATS))
+	for i, format := range judge.FORMATS {
+		names[i] = string(format)
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// symbolizeReports symbolizes the sanitizer reports, so that their headlines have the source lines.
+func symbolizeReports(directory string, results []judge.Result) {
+	for _, result := range results {
+		if report := result.Execution.Report; report != nil {
+			_ = report.Symbolize(executablePath(directory))
+		}
+	}
+}
+
+// runTests runs the compiled solution of the problem against all its test cases,
+// calling report, if not nil, with each result in the order of the cases.
+func runTests(directory string, report func(judge.Result)) ([]judge.Result, error) {
+	cases, err := judge.Discover(directory)
+	if err != nil {
+		return nil, err
+	}
+	if len(cases) == 0 {
+		return nil, fmt.Errorf("no test cases found in %s", testsDir(directory))
+	}
+
+	var limits sandbox.Limits
+	if sandboxed := sandboxLimits(GetTimeLimit()); sandboxed != nil {
+		limits = *sandboxed
+	}
+	jobs := testJobs
+	if testSerial {
+		jobs = 1
+	}
+
+	return judge.RunCases(commandPath(executablePath(directory)), cases, judge.Options{TimeLimit: GetTimeLimit(), Limits: limits, Jobs: jobs}, report)
+}
+
+// dryRunTests prints the command running each test case.
+func dryRunTests(directory string) error {
+	cases, err := judge.Discover(directory)
+	if err != nil {
+		return err
+	}
+	for _, c := range cases {
+		execute := shell.Command(commandPath(executablePath(directory)))
+		execute.StdinFile = c.Input
+		if err := runner.Run(execute); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func testsDir(directory string) string {
+	return filepath.Join(directory, judge.TESTS_DIR)
+}
+
+func formatDuration(d time.Duration) string {
+	return fmt.Sprintf("%d ms", d.Milliseconds())
+}
+
+// printResult prints the verdict of the case, with the details if it failed.
+// The output of WA is shown as a diff around the first differing token.
+func printResult(w io.Writer, directory string, result judge.Result, diffOptions diff.Options) {
+	line := fmt.Sprintf("%-4s%-16s%8s", result.Verdict, result.Case.Name, formatDuration(result.Time))
+	if report := result.Execution.Report; report != nil {
+		_ = report.Symbolize(executablePath(directory))
+		line += "  " + report.Headline(sourcePath(directory))
+	} else if (result.Verdict == judge.RE || result.Verdict == judge.MLE) && result.Execution.Err != nil {
+		line += "  " + result.Execution.Err.Error()
+	}
+	fmt.Fprintln(w, line)
+
+	if result.Verdict == judge.WA {
+		var b strings.Builder
+		_ = diff.Write(&b, result.Expected, result.Stdout, diffOptions)
+		fmt.Fprint(w, indent([]byte(b.String())))
+	}
+}
+
+// testEvent returns the event of the test for the history, with the number of cases of each verdict.
+func testEvent(summary judge.Summary) history.Event {
+	verdicts := map[string]int{}
+	for verdict, count := range summary {
+		verdicts[string(verdict)] = count
+	}
+
+	return history.Event{Kind: history.EVENT_TEST, Verdict: string(summary.Verdict()), Verdicts: verdicts}
+}
+
+// colorEnabled reports whether to color the output to the file: only for a terminal,
+// and not when NO_COLOR is set (https://no-color.org).
+func colorEnabled(f *os.File) bool {
+	return os.Getenv("NO_COLOR") == "" && isTerminal(f)
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func indent(output []byte) string {
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(string(output), "\n") {
+		if line == "" {
+			continue
+		}
+		b.WriteString("    ")
+		b.WriteString(line)
+	}
+	if b.Len() != 0 && !strings.HasSuffix(b.String(), "\n") {
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// summaryLine returns a compact line such as "WA 2/3 AC (failed: sample-2, max 15 ms)".
+func summaryLine(results []judge.Result) string {
+	summary := judge.Summarize(results)
+	var maxTime time.Duration
+	var failed []string
+	for _, result := range results {
+		maxTime = max(maxTime, result.Time)
+		if result.Verdict != judge.AC && result.Verdict != judge.OK {
+			failed = append(failed, result.Case.Name)
+		}
+	}
+
+	line := fmt.Sprintf("%s %d/%d AC", summary.Verdict(), summary[judge.AC], len(results))
+	if len(failed) != 0 {
+		return fmt.Sprintf("%s (failed: %s, max %s)", line, strings.Join(failed, ", "), formatDuration(maxTime))
+	}
+
+	return fmt.Sprintf("%s (max %s)", line, formatDuration(maxTime))
+}
+
+// addJobsFlags adds --jobs and --serial, which are shared by the commands running the tests.
+func addJobsFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVarP(&testJobs, "jobs", "j", runtime.GOMAXPROCS(0), "number of test cases run at once")
+	cmd.Flags().BoolVar(&testSerial, "serial", false, "run the test cases one by one for trustworthy timing (same as --jobs 1)")
+}
+
+func init() {
+	rootCmd.AddCommand(testCmd)
+	addJobsFlags(testCmd)
+	testCmd.Flags().StringVar(&testSplit, "split", "", `split the inputs of failed cases of "T test cases" to find the failing one: "lines:K" or "header:I"`)
+	testCmd.Flags().BoolVar(&testBacktrace, "backtrace", false, `print the backtrace of the cases judged as RE, run in the debugger (see "acutils-cli debug --help")`)
+	testCmd.Flags().StringVar(&testNaive, "naive", "", "reference solution to compare the split cases with (default: naive.cpp in the problem directory)")
+	testCmd.Flags().StringVar(&testFormat, "format", string(judge.FORMAT_TEXT), "format of the results: "+formatNames())
+}
@@ -0,0 +1,179 @@
+/*
+Copyright © 2024 lemolatoon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+)
+
+// WATCH_DEBOUNCE is how long to wait after the last change before rebuilding.
+// Editors often write several files (temporary files, backups) on a single save.
+const WATCH_DEBOUNCE = 200 * time.Millisecond
+
+// watchCmd represents the watch command
+var watchCmd = &cobra.Command{
+	Use:   "watch problem-name",
+	Short: "Rebuild and rerun the tests of the problem on every save.",
+	Long: `Rebuild and rerun the tests of the problem on every save.
+
+Watch the problem directory, its tests directory and the library headers in
+INCLUDE_PATHS. On changes, compile main.cpp if needed (always when a header
+changed), run all the test cases and print a compact verdict line.
+The cases run in parallel as with test (see --jobs and --serial).`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("problem-name must be provided")
+		}
+		cmd.SilenceUsage = true
+
+		directory := args[0]
+		if _, err := os.Stat(directory); err != nil {
+			return err
+		}
+
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			return err
+		}
+		defer watcher.Close()
+
+		if err := watcher.Add(directory); err != nil {
+			return err
+		}
+		if _, err := os.Stat(testsDir(directory)); err == nil {
+			if err := watcher.Add(testsDir(directory)); err != nil {
+				return err
+			}
+		}
+		for _, includePath := range GetIncludePaths() {
+			if err := addWatchRecursive(watcher, includePath); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: cannot watch %s: %v\n", includePath, err)
+			}
+		}
+
+		watchIteration(directory, false)
+		fmt.Printf("watching %s (Ctrl-C to stop)\n", directory)
+
+		var debounce <-chan time.Time
+		forceCompile := false
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return nil
+				}
+				if ignoreWatchEvent(event) {
+					continue
+				}
+				if event.Has(fsnotify.Create) && filepath.Clean(event.Name) == filepath.Clean(testsDir(directory)) {
+					_ = watcher.Add(event.Name)
+				}
+				if filepath.Clean(event.Name) != filepath.Clean(sourcePath(directory)) && isSourceFile(event.Name) {
+					forceCompile = true
+				}
+				debounce = time.After(WATCH_DEBOUNCE)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return nil
+				}
+				fmt.Fprintf(os.Stderr, "watch error: %v\n", err)
+			case <-debounce:
+				watchIteration(directory, forceCompile)
+				forceCompile = false
+				debounce = nil
+			}
+		}
+	},
+}
+
+// watchIteration compiles the solution if needed and prints a compact verdict line of its tests.
+func watchIteration(directory string, forceCompile bool) {
+	timestamp := time.Now().Format("15:04:05")
+	if err := compileIfNeeded(directory, forceCompile); err != nil {
+		fmt.Printf("[%s] CE %v\n", timestamp, err)
+		return
+	}
+	results, err := runTests(directory, nil)
+	if err != nil {
+		fmt.Printf("[%s] %v\n", timestamp, err)
+		return
+	}
+	fmt.Printf("[%s] %s\n", timestamp, summaryLine(results))
+}
+
+func addWatchRecursive(watcher *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if strings.HasPrefix(entry.Name(), ".") && path != root {
+				return filepath.SkipDir
+			}
+			return watcher.Add(path)
+		}
+		return nil
+	})
+}
+
+// ignoreWatchEvent reports whether the event is irrelevant, e.g. the outputs of compile
+// and temporary files of editors.
+func ignoreWatchEvent(event fsnotify.Event) bool {
+	if event.Op == fsnotify.Chmod {
+		return true
+	}
+
+	name := filepath.Base(event.Name)
+	switch name {
+	case "a.out", BENCH_EXECUTABLE, DEBUG_EXECUTABLE, COMPILE_LOG_FILE, "4913":
+		return true
+	}
+	return strings.HasPrefix(name, ".") ||
+		strings.HasPrefix(name, "#") ||
+		strings.HasSuffix(name, "~") ||
+		strings.HasSuffix(name, ".swp") ||
+		strings.HasSuffix(name, ".swx") ||
+		strings.HasSuffix(name, ".tmp") ||
+		strings.Contains(name, "___jb_")
+}
+
+func isSourceFile(path string) bool {
+	switch filepath.Ext(path) {
+	case ".cpp", ".cc", ".cxx", ".hpp", ".h", ".hh", ".hxx":
+		return true
+	}
+	return false
+}
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+	addJobsFlags(watchCmd)
+}
@@ -0,0 +1,255 @@
+/*
+Copyright © 2024 lemolatoon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Package diagnostic parses GCC/Clang compiler diagnostics and condenses them.
+package diagnostic
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Diagnostic is a single compiler message.
+type Diagnostic struct {
+	// Kind is "error", "fatal error", "warning" or "note".
+	Kind     string
+	File     string
+	Line     int
+	Column   int
+	Message  string
+	Children []Diagnostic
+}
+
+// Location returns "file:line:col", omitting the parts which are unknown.
+func (d Diagnostic) Location() string {
+	switch {
+	case d.File == "":
+		return ""
+	case d.Line == 0:
+		return d.File
+	case d.Column == 0:
+		return fmt.Sprintf("%s:%d", d.File, d.Line)
+	default:
+		return fmt.Sprintf("%s:%d:%d", d.File, d.Line, d.Column)
+	}
+}
+
+// String formats the diagnostic the same way as GCC's text output.
+func (d Diagnostic) String() string {
+	if location := d.Location(); location != "" {
+		return fmt.Sprintf("%s: %s: %s", location, d.Kind, d.Message)
+	}
+	return fmt.Sprintf("%s: %s", d.Kind, d.Message)
+}
+
+// IsError reports whether the diagnostic makes the compilation fail.
+func (d Diagnostic) IsError() bool {
+	return d.Kind == "error" || d.Kind == "fatal error"
+}
+
+// JSONFlag makes GCC report diagnostics in the JSON format Parse understands.
+const JSONFlag = "-fdiagnostics-format=json"
+
+type jsonPosition struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
+type jsonDiagnostic struct {
+	Kind      string `json:"kind"`
+	Message   string `json:"message"`
+	Locations []struct {
+		Caret jsonPosition `json:"caret"`
+	} `json:"locations"`
+	Children []jsonDiagnostic `json:"children"`
+}
+
+func (j jsonDiagnostic) toDiagnostic() Diagnostic {
+	d := Diagnostic{Kind: j.Kind, Message: j.Message}
+	if len(j.Locations) != 0 {
+		caret := j.Locations[0].Caret
+		d.File, d.Line, d.Column = caret.File, caret.Line, caret.Column
+	}
+	for _, child := range j.Children {
+		d.Children = append(d.Children, child.toDiagnostic())
+	}
+
+	return d
+}
+
+// Parse parses the output of the compiler.
+// The output is read as the JSON array of -fdiagnostics-format=json if it starts with one,
+// and messages following it (e.g. from the linker) are parsed as text.
+func Parse(output []byte) []Diagnostic {
+	raw, text := splitJSON(output)
+	var diagnostics []Diagnostic
+	for _, r := range raw {
+		diagnostics = append(diagnostics, r.toDiagnostic())
+	}
+
+	return append(diagnostics, ParseText(text)...)
+}
+
+// Text returns the output without the JSON array at its start, e.g. the "[]" GCC writes
+// for a clean compilation with -fdiagnostics-format=json.
+func Text(output []byte) []byte {
+	_, text := splitJSON(output)
+	return text
+}
+
+// splitJSON splits the output into the JSON array at its start, if any, and the text following it.
+func splitJSON(output []byte) ([]jsonDiagnostic, []byte) {
+	trimmed := bytes.TrimLeft(output, " \t\r\n")
+	if !bytes.HasPrefix(trimmed, []byte("[")) {
+		return nil, output
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(trimmed))
+	var raw []jsonDiagnostic
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, output
+	}
+
+	return raw, bytes.TrimLeft(trimmed[decoder.InputOffset():], "\r\n")
+}
+
+var textDiagnosticPattern = regexp.MustCompile(`^(.+?):(\d+):(?:(\d+):)? (fatal error|error|warning|note): (.*)$`)
+var textLocationlessPattern = regexp.MustCompile(`^(?:(.+?): )?(fatal error|error|warning): (.*)$`)
+var linkerErrorPattern = regexp.MustCompile(`^(.+?):(?:\(.+\)|.+\.o): (undefined reference to .*)$`)
+
+// ParseText parses the human-readable output of GCC, Clang and the linker.
+// Notes are attached to the preceding error or warning.
+func ParseText(output []byte) []Diagnostic {
+	var diagnostics []Diagnostic
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		var d Diagnostic
+		if match := textDiagnosticPattern.FindStringSubmatch(line); match != nil {
+			d.File = match[1]
+			d.Line, _ = strconv.Atoi(match[2])
+			d.Column, _ = strconv.Atoi(match[3])
+			d.Kind, d.Message = match[4], match[5]
+		} else if match := linkerErrorPattern.FindStringSubmatch(line); match != nil {
+			d.File, d.Kind, d.Message = match[1], "error", match[2]
+		} else if match := textLocationlessPattern.FindStringSubmatch(line); match != nil && !strings.HasPrefix(line, " ") {
+			d.File, d.Kind, d.Message = match[1], match[2], match[3]
+		} else {
+			continue
+		}
+
+		if d.Kind == "note" && len(diagnostics) != 0 {
+			last := &diagnostics[len(diagnostics)-1]
+			last.Children = append(last.Children, d)
+			continue
+		}
+		diagnostics = append(diagnostics, d)
+	}
+
+	return diagnostics
+}
+
+// Count returns the number of errors and warnings, excluding notes.
+func Count(diagnostics []Diagnostic) (errors int, warnings int) {
+	for _, d := range diagnostics {
+		switch {
+		case d.IsError():
+			errors++
+		case d.Kind == "warning":
+			warnings++
+		}
+	}
+
+	return errors, warnings
+}
+
+// Summarize writes the first error or warning of each location with its source line and a caret.
+// Notes, which are most of the template-instantiation noise, are omitted.
+func Summarize(w io.Writer, diagnostics []Diagnostic) error {
+	seen := make(map[string]bool)
+	sources := make(map[string][]string)
+	for _, d := range diagnostics {
+		if !d.IsError() && d.Kind != "warning" {
+			continue
+		}
+		key := d.Location()
+		if key != "" {
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+
+		if _, err := fmt.Fprintln(w, d); err != nil {
+			return err
+		}
+		if d.File == "" || d.Line == 0 {
+			continue
+		}
+		lines, ok := sources[d.File]
+		if !ok {
+			if content, err := os.ReadFile(d.File); err == nil {
+				lines = strings.Split(string(content), "\n")
+			}
+			sources[d.File] = lines
+		}
+		if d.Line > len(lines) {
+			continue
+		}
+		if _, err := fmt.Fprint(w, sourceExcerpt(lines[d.Line-1], d.Line, d.Column)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func sourceExcerpt(source string, line int, column int) string {
+	number := strconv.Itoa(line)
+	excerpt := fmt.Sprintf(" %s | %s\n", number, source)
+	if column > 0 {
+		// Keep tabs so that the caret lines up with the source line.
+		var padding strings.Builder
+		for i, r := range source {
+			if i >= column-1 {
+				break
+			}
+			if r == '\t' {
+				padding.WriteRune('\t')
+			} else {
+				padding.WriteRune(' ')
+			}
+		}
+		excerpt += fmt.Sprintf(" %s | %s^\n", strings.Repeat(" ", len(number)), padding.String())
+	}
+
+	return excerpt
+}
@@ -0,0 +1,92 @@
+package diagnostic
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const gccJSONOutput = `[{"kind": "error", "children": [{"kind": "note", "locations": [{"caret": {"line": 1276, "file": "/usr/include/c++/12/bits/stl_vector.h", "column": 7}}], "message": "candidate: 'void std::vector<int>::push_back(const value_type&)'"}], "locations": [{"caret": {"line": 2, "file": "main.cpp", "column": 5}}], "message": "no matching function for call to 'push_back(const char [2])'"}, {"kind": "warning", "children": [], "locations": [{"caret": {"line": 3, "file": "main.cpp", "column": 7}}], "message": "unused variable 'y'"}]
+/usr/bin/ld: /tmp/cc.o: in function ` + "`main':" + `
+main.cpp:(.text+0x5): undefined reference to ` + "`f()'" + `
+collect2: error: ld returned 1 exit status
+`
+
+func TestParseJSONWithLinkerErrors(t *testing.T) {
+	diagnostics := Parse([]byte(gccJSONOutput))
+	if len(diagnostics) != 4 {
+		t.Fatalf("expected 4 diagnostics, got %d: %v", len(diagnostics), diagnostics)
+	}
+
+	first := diagnostics[0]
+	if first.Location() != "main.cpp:2:5" || !first.IsError() || len(first.Children) != 1 {
+		t.Fatalf("unexpected first diagnostic: %+v", first)
+	}
+	if !strings.Contains(diagnostics[2].Message, "undefined reference") {
+		t.Fatalf("expected linker error, got %+v", diagnostics[2])
+	}
+
+	errors, warnings := Count(diagnostics)
+	if errors != 3 || warnings != 1 {
+		t.Fatalf("expected 3 errors and 1 warning, got %d and %d", errors, warnings)
+	}
+}
+
+func TestTextDropsJSONDiagnostics(t *testing.T) {
+	if text := Text([]byte("[]\n")); len(text) != 0 {
+		t.Fatalf("expected no text for a clean compilation, got %q", text)
+	}
+	if text := string(Text([]byte(gccJSONOutput))); !strings.HasPrefix(text, "/usr/bin/ld: ") {
+		t.Fatalf("expected the linker output after the JSON diagnostics, got %q", text)
+	}
+	if text := string(Text([]byte("collect2: error\n"))); text != "collect2: error\n" {
+		t.Fatalf("expected text output as it is, got %q", text)
+	}
+}
+
+func TestParseTextAttachesNotes(t *testing.T) {
+	output := `main.cpp: In function 'int main()':
+main.cpp:4:12: error: 'z' was not declared in this scope
+    4 |   int y = z;
+      |           ^
+main.cpp:4:12: note: suggested alternative: 'y'
+main.cpp:5:1: fatal error: expected '}' at end of input
+`
+	diagnostics := ParseText([]byte(output))
+	if len(diagnostics) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %d: %v", len(diagnostics), diagnostics)
+	}
+	if len(diagnostics[0].Children) != 1 || diagnostics[0].Children[0].Kind != "note" {
+		t.Fatalf("expected note attached to the first error: %+v", diagnostics[0])
+	}
+	if diagnostics[1].Kind != "fatal error" || diagnostics[1].Line != 5 {
+		t.Fatalf("unexpected second diagnostic: %+v", diagnostics[1])
+	}
+}
+
+func TestSummarizeShowsFirstErrorPerLocation(t *testing.T) {
+	tmp := t.TempDir()
+	source := filepath.Join(tmp, "main.cpp")
+	if err := os.WriteFile(source, []byte("int main() {\n  return x;\n}\n"), 0o644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+
+	diagnostics := []Diagnostic{
+		{Kind: "error", File: source, Line: 2, Column: 10, Message: "'x' was not declared in this scope",
+			Children: []Diagnostic{{Kind: "note", File: source, Line: 2, Column: 10, Message: "noise"}}},
+		{Kind: "error", File: source, Line: 2, Column: 10, Message: "duplicated"},
+	}
+	var out bytes.Buffer
+	if err := Summarize(&out, diagnostics); err != nil {
+		t.Fatalf("summarize failed: %v", err)
+	}
+
+	want := source + ":2:10: error: 'x' was not declared in this scope\n" +
+		" 2 |   return x;\n" +
+		"   |          ^\n"
+	if out.String() != want {
+		t.Fatalf("summary mismatch:\nwant: %q\ngot : %q", want, out.String())
+	}
+}
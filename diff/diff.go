@@ -0,0 +1,349 @@
+/*
+Copyright © 2024 lemolatoon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Package diff shows where the output of a solution differs from the expected one,
+// as a unified diff of the lines around the first differing token, with long lines
+// cut to the columns around it.
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Options are the options of the diff.
+type Options struct {
+	// Color colors the diff with ANSI escape sequences and highlights the first differing token.
+	Color bool
+	// Context is the number of lines shown before the first difference.
+	Context int
+	// MaxLines is the number of lines of each output shown from the first difference.
+	// The rest is truncated.
+	MaxLines int
+	// Width is the number of bytes of each line shown around the first difference.
+	// Wider lines are cut with "…". Zero means unlimited.
+	Width int
+}
+
+// DefaultOptions shows 3 lines of context and up to 10 lines from the first difference,
+// and 80 bytes of each line around it.
+var DefaultOptions = Options{Context: 3, MaxLines: 10, Width: 80}
+
+const ellipsis = "…"
+
+const (
+	colorReset   = "\x1b[0m"
+	colorRed     = "\x1b[31m"
+	colorGreen   = "\x1b[32m"
+	colorCyan    = "\x1b[36m"
+	colorReverse = "\x1b[7m"
+)
+
+// token is a whitespace-separated token with its position.
+type token struct {
+	text []byte
+	line int
+	// column is the byte offset in the line.
+	column int
+}
+
+// tokenize splits the output at the same whitespace as bytes.Fields, which judge.Equal uses,
+// including non-ASCII spaces such as U+3000.
+func tokenize(output []byte) []token {
+	var tokens []token
+	for lineNumber, line := range splitLines(output) {
+		start := -1
+		for column, r := range line {
+			switch {
+			case unicode.IsSpace(r) && start >= 0:
+				tokens = append(tokens, token{text: []byte(line[start:column]), line: lineNumber, column: start})
+				start = -1
+			case !unicode.IsSpace(r) && start < 0:
+				start = column
+			}
+		}
+		if start >= 0 {
+			tokens = append(tokens, token{text: []byte(line[start:]), line: lineNumber, column: start})
+		}
+	}
+
+	return tokens
+}
+
+func splitLines(output []byte) []string {
+	text := strings.TrimSuffix(string(output), "\n")
+	if text == "" {
+		return nil
+	}
+
+	return strings.Split(text, "\n")
+}
+
+// Mismatch is the first token which differs between the outputs.
+type Mismatch struct {
+	// Index is the 0-based index of the token.
+	Index int
+	// Expected and Actual are the tokens, or "" if the output ended.
+	Expected string
+	Actual   string
+	// ExpectedLine and ActualLine are the 0-based lines of the tokens, or the number of
+	// lines if the output ended.
+	ExpectedLine int
+	ActualLine   int
+
+	expectedColumn int
+	actualColumn   int
+}
+
+// FirstMismatch returns the first differing token, comparing the outputs token by token
+// as judge.Equal does. It returns nil if the outputs are equal.
+func FirstMismatch(expected []byte, actual []byte) *Mismatch {
+	expectedTokens := tokenize(expected)
+	actualTokens := tokenize(actual)
+	for i := 0; i < max(len(expectedTokens), len(actualTokens)); i++ {
+		if i < len(expectedTokens) && i < len(actualTokens) && bytes.Equal(expectedTokens[i].text, actualTokens[i].text) {
+			continue
+		}
+
+		m := &Mismatch{Index: i, ExpectedLine: len(splitLines(expected)), ActualLine: len(splitLines(actual)), expectedColumn: -1, actualColumn: -1}
+		if i < len(expectedTokens) {
+			m.Expected = string(expectedTokens[i].text)
+			m.ExpectedLine, m.expectedColumn = expectedTokens[i].line, expectedTokens[i].column
+		}
+		if i < len(actualTokens) {
+			m.Actual = string(actualTokens[i].text)
+			m.ActualLine, m.actualColumn = actualTokens[i].line, actualTokens[i].column
+		}
+		return m
+	}
+
+	return nil
+}
+
+// Summary describes the mismatch, e.g. `token 5 (line 2): expected "3", got "4"`.
+func (m *Mismatch) Summary() string {
+	describe := func(text string) string {
+		if text == "" {
+			return "end of output"
+		}
+		return fmt.Sprintf("%q", text)
+	}
+	location := fmt.Sprintf("line %d", m.ExpectedLine+1)
+	if m.ExpectedLine != m.ActualLine {
+		location = fmt.Sprintf("expected line %d, actual line %d", m.ExpectedLine+1, m.ActualLine+1)
+	}
+
+	return fmt.Sprintf("token %d (%s): expected %s, got %s", m.Index+1, location, describe(m.Expected), describe(m.Actual))
+}
+
+// Write writes the summary of the first mismatch and a unified diff of the lines around it.
+// Lines which differ only in whitespace are shown as unchanged, as they are judged equal.
+func Write(w io.Writer, expected []byte, actual []byte, opts Options) error {
+	m := FirstMismatch(expected, actual)
+	if m == nil {
+		return nil
+	}
+	expectedLines := splitLines(expected)
+	actualLines := splitLines(actual)
+
+	// Both outputs are equal up to the first mismatch except for whitespace,
+	// so the context starts at the same line of each.
+	start := max(0, min(m.ExpectedLine, m.ActualLine)-opts.Context)
+	expectedEnd := min(len(expectedLines), m.ExpectedLine+opts.MaxLines)
+	actualEnd := min(len(actualLines), m.ActualLine+opts.MaxLines)
+	expectedWindow := expectedLines[min(start, len(expectedLines)):expectedEnd]
+	actualWindow := actualLines[min(start, len(actualLines)):actualEnd]
+
+	if _, err := fmt.Fprintf(w, "first difference at %s\n", m.Summary()); err != nil {
+		return err
+	}
+	header := fmt.Sprintf("@@ -%d,%d +%d,%d @@", start+1, len(expectedWindow), start+1, len(actualWindow))
+	if _, err := fmt.Fprintln(w, paint(header, colorCyan, opts.Color)); err != nil {
+		return err
+	}
+
+	// Long lines are shown around the first differing token, or around the token of the
+	// other output if one has ended.
+	expectedCenter, actualCenter := m.expectedColumn, m.actualColumn
+	if expectedCenter < 0 {
+		expectedCenter = max(actualCenter, 0)
+	}
+	if actualCenter < 0 {
+		actualCenter = expectedCenter
+	}
+	for _, edit := range diffLines(expectedWindow, actualWindow) {
+		var line string
+		switch edit.op {
+		case ' ':
+			line = " " + clip(edit.text, expectedCenter, -1, 0, opts)
+		case '-':
+			column := -1
+			if start+edit.index == m.ExpectedLine {
+				column = m.expectedColumn
+			}
+			line = paint("-"+clip(edit.text, expectedCenter, column, len(m.Expected), opts), colorRed, opts.Color)
+		case '+':
+			column := -1
+			if start+edit.index == m.ActualLine {
+				column = m.actualColumn
+			}
+			line = paint("+"+clip(edit.text, actualCenter, column, len(m.Actual), opts), colorGreen, opts.Color)
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+
+	if rest := max(len(expectedLines)-expectedEnd, len(actualLines)-actualEnd); rest > 0 {
+		_, err := fmt.Fprintf(w, "... (%d more lines of expected, %d more lines of actual)\n", len(expectedLines)-expectedEnd, len(actualLines)-actualEnd)
+		return err
+	}
+
+	return nil
+}
+
+// paint colors the text, restoring the color after a highlight in it.
+func paint(text string, color string, enabled bool) string {
+	if !enabled {
+		return text
+	}
+
+	return color + strings.ReplaceAll(text, colorReset, colorReset+color) + colorReset
+}
+
+// clip cuts the line to opts.Width bytes around the center, marking the cuts with "…", and
+// highlights the token of the length at the column, if it is not -1.
+func clip(line string, center int, column int, length int, opts Options) string {
+	from, to := 0, len(line)
+	if opts.Width > 0 && len(line) > opts.Width {
+		from = max(0, min(center-opts.Width/2, len(line)-opts.Width))
+		to = from + opts.Width
+		// Do not cut a multi-byte character.
+		for from > 0 && !utf8.RuneStart(line[from]) {
+			from--
+		}
+		for to < len(line) && !utf8.RuneStart(line[to]) {
+			to--
+		}
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString(ellipsis)
+	}
+	if opts.Color && column >= 0 {
+		// The token may be cut, too.
+		tokenStart, tokenEnd := min(max(column, from), to), min(max(column+length, from), to)
+		b.WriteString(line[from:tokenStart] + colorReverse + line[tokenStart:tokenEnd] + colorReset + line[tokenEnd:to])
+	} else {
+		b.WriteString(line[from:to])
+	}
+	if to < len(line) {
+		b.WriteString(ellipsis)
+	}
+
+	return b.String()
+}
+
+type edit struct {
+	op   byte
+	text string
+	// index is the index of the line in its side.
+	index int
+}
+
+// diffLines returns the edits from a to b by the longest common subsequence of the lines,
+// comparing them ignoring whitespace.
+func diffLines(a []string, b []string) []edit {
+	normalize := func(lines []string) []string {
+		normalized := make([]string, len(lines))
+		for i, line := range lines {
+			normalized[i] = strings.Join(strings.Fields(line), " ")
+		}
+		return normalized
+	}
+	na, nb := normalize(a), normalize(b)
+
+	// lcs[i][j] is the length of the LCS of na[i:] and nb[j:].
+	lcs := make([][]int, len(na)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(nb)+1)
+	}
+	for i := len(na) - 1; i >= 0; i-- {
+		for j := len(nb) - 1; j >= 0; j-- {
+			if na[i] == nb[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var edits []edit
+	i, j := 0, 0
+	for i < len(na) || j < len(nb) {
+		switch {
+		case i < len(na) && j < len(nb) && na[i] == nb[j]:
+			edits = append(edits, edit{op: ' ', text: b[j], index: j})
+			i++
+			j++
+		case j < len(nb) && (i == len(na) || lcs[i][j+1] >= lcs[i+1][j]):
+			edits = append(edits, edit{op: '+', text: b[j], index: j})
+			j++
+		default:
+			edits = append(edits, edit{op: '-', text: a[i], index: i})
+			i++
+		}
+	}
+
+	return reorder(edits)
+}
+
+// reorder moves the deletions before the insertions in each run of changes, as diff -u does.
+func reorder(edits []edit) []edit {
+	ordered := make([]edit, 0, len(edits))
+	for i := 0; i < len(edits); {
+		if edits[i].op == ' ' {
+			ordered = append(ordered, edits[i])
+			i++
+			continue
+		}
+		j := i
+		for j < len(edits) && edits[j].op != ' ' {
+			j++
+		}
+		for _, op := range []byte{'-', '+'} {
+			for _, e := range edits[i:j] {
+				if e.op == op {
+					ordered = append(ordered, e)
+				}
+			}
+		}
+		i = j
+	}
+
+	return ordered
+}
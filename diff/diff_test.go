@@ -0,0 +1,131 @@
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestFirstMismatch(t *testing.T) {
+	if m := FirstMismatch([]byte("1 2\n3\n"), []byte("1  2 3")); m != nil {
+		t.Fatalf("expected no mismatch, got %+v", m)
+	}
+
+	m := FirstMismatch([]byte("Yes\n1 2 3\n"), []byte("Yes\n1 2 4\n"))
+	if m == nil || m.Index != 3 || m.Expected != "3" || m.Actual != "4" || m.ExpectedLine != 1 {
+		t.Fatalf("unexpected mismatch: %+v", m)
+	}
+	if want := `token 4 (line 2): expected "3", got "4"`; m.Summary() != want {
+		t.Fatalf("summary mismatch:\nwant: %s\ngot : %s", want, m.Summary())
+	}
+
+	m = FirstMismatch([]byte("1\n2\n"), []byte("1\n"))
+	if m == nil || m.Expected != "2" || m.Actual != "" {
+		t.Fatalf("unexpected mismatch: %+v", m)
+	}
+	if want := `token 2 (line 2): expected "2", got end of output`; m.Summary() != want {
+		t.Fatalf("summary mismatch:\nwant: %s\ngot : %s", want, m.Summary())
+	}
+}
+
+func TestFirstMismatchAgreesWithFieldsOnUnicodeSpaces(t *testing.T) {
+	// judge.Equal compares the outputs by bytes.Fields, which splits at any Unicode space.
+	expected := []byte("1\u00a02\u30003\n")
+	for _, actual := range []string{"1 2 3\n", "1\u00a02\u3000 4\n", "1\u00852\n"} {
+		equal := slices.EqualFunc(bytes.Fields(expected), bytes.Fields([]byte(actual)), bytes.Equal)
+		if m := FirstMismatch(expected, []byte(actual)); (m == nil) != equal {
+			t.Fatalf("FirstMismatch disagrees with bytes.Fields for %q: %+v", actual, m)
+		}
+	}
+
+	m := FirstMismatch(expected, []byte("1\u00a02\u3000 4\n"))
+	if m == nil || m.Index != 2 || m.Expected != "3" || m.Actual != "4" {
+		t.Fatalf("unexpected mismatch: %+v", m)
+	}
+}
+
+func TestWriteShowsLinesAroundFirstMismatch(t *testing.T) {
+	var expected, actual strings.Builder
+	for i := 1; i <= 100; i++ {
+		fmt.Fprintf(&expected, "%d\n", i)
+		if i == 50 {
+			fmt.Fprintf(&actual, "%d \n", -i)
+		} else {
+			fmt.Fprintf(&actual, "%d \n", i)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := Write(&out, []byte(expected.String()), []byte(actual.String()), Options{Context: 2, MaxLines: 3}); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	want := `first difference at token 50 (line 50): expected "50", got "-50"
+@@ -48,5 +48,5 @@
+ 48 
+ 49 
+-50
++-50 
+ 51 
+ 52 
+... (48 more lines of expected, 48 more lines of actual)
+`
+	if out.String() != want {
+		t.Fatalf("diff mismatch:\nwant:\n%s\ngot:\n%s", want, out.String())
+	}
+}
+
+func TestWriteClipsLongLinesAroundFirstMismatch(t *testing.T) {
+	expected := make([]string, 200000)
+	actual := make([]string, 200000)
+	for i := range expected {
+		expected[i] = strconv.Itoa(i)
+		actual[i] = strconv.Itoa(i)
+	}
+	actual[100000] = "x"
+	var out bytes.Buffer
+	err := Write(&out, []byte(strings.Join(expected, " ")+"\n"), []byte(strings.Join(actual, " ")+"\n"), Options{Color: true, Context: 3, MaxLines: 10, Width: 40})
+	if err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 4 || out.Len() > 300 {
+		t.Fatalf("expected a short diff of the single line, got %d bytes:\n%s", out.Len(), out.String())
+	}
+	for i, want := range []string{colorReverse + "100000" + colorReset, colorReverse + "x" + colorReset} {
+		line := lines[i+2]
+		if !strings.Contains(line, want) || strings.Count(line, ellipsis) != 2 {
+			t.Fatalf("expected the line to be cut around %q on both sides: %q", want, line)
+		}
+	}
+
+	out.Reset()
+	if err := Write(&out, []byte(strings.Repeat("あ", 50)+" 1 "+strings.Repeat("い", 50)+"\n"), []byte(strings.Repeat("あ", 50)+" 2 "+strings.Repeat("い", 50)+"\n"), Options{MaxLines: 5, Width: 10}); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if !utf8.ValidString(out.String()) || !strings.Contains(out.String(), "-"+ellipsis+"あ") {
+		t.Fatalf("expected multi-byte characters not to be cut: %q", out.String())
+	}
+}
+
+func TestWriteHighlightsFirstMismatchWithColor(t *testing.T) {
+	var out bytes.Buffer
+	if err := Write(&out, []byte("1 2 3\n"), []byte("1 9 3\n"), Options{Color: true, MaxLines: 5}); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if !strings.Contains(out.String(), colorRed+"-1 "+colorReverse+"2"+colorReset+colorRed+" 3"+colorReset) ||
+		!strings.Contains(out.String(), colorGreen+"+1 "+colorReverse+"9"+colorReset+colorGreen+" 3"+colorReset) {
+		t.Fatalf("expected the first differing token to be highlighted: %q", out.String())
+	}
+
+	out.Reset()
+	if err := Write(&out, []byte("1 2 3\n"), []byte("1 9 3\n"), Options{MaxLines: 5}); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if strings.Contains(out.String(), "\x1b[") {
+		t.Fatalf("expected no escape sequences without color: %q", out.String())
+	}
+}
@@ -0,0 +1,356 @@
+/*
+Copyright © 2024 lemolatoon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Package gen generates random inputs from a small spec language, so that stress tests
+// do not need a generator program for every problem.
+//
+// A spec has one line of the input per line (or per ";"), made of items separated by
+// spaces, e.g.
+//
+//	N:int[2,2e5] M:int[N-1,N*(N-1)/2]
+//	A:int[1,1e9]*N
+//	graph(N,M)
+//
+// The items are:
+//
+//	int[lo,hi]           an integer in [lo, hi]
+//	str[lo,hi]@abc       a string of length in [lo, hi] over the alphabet (default a-z), e.g. @a-z0-9
+//	perm(n)              a permutation of 1..n
+//	tree(n)              n-1 lines of the edges "u v" of a random tree on 1..n
+//	graph(n,m)           m lines of the edges of a random connected graph without self-loops and multi-edges
+//
+// tree and graph take an optional item for the weights of the edges, e.g. tree(N,int[1,1e9]),
+// and must be alone on their line. "NAME:" names an int (or the length of a str) to use
+// it in the later bounds, "*count" repeats an item on the same line, and "{ items }*count" repeats a line.
+// Bounds are integer expressions with + - * / and parentheses, where 2e5 means 200000.
+package gen
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"strconv"
+)
+
+// MAX_ELEMENTS limits the number of values generated, not to fill the disk by a typo.
+const MAX_ELEMENTS = 10_000_000
+
+// Spec is a parsed generator spec.
+type Spec struct {
+	lines []line
+}
+
+type line struct {
+	items []item
+	// repeat is the number of times the line is repeated, or nil for once.
+	repeat expr
+}
+
+type item struct {
+	name     string
+	kind     string
+	args     []expr
+	alphabet []byte
+	// count repeats the item on the line, or nil for once.
+	count  expr
+	weight *item
+	pos    position
+}
+
+type position struct {
+	line   int
+	column int
+}
+
+func (p position) String() string {
+	return fmt.Sprintf("%d:%d", p.line, p.column)
+}
+
+// Error is an error in a spec, at the position of the item causing it.
+type Error struct {
+	Pos     position
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("gen spec %s: %s", e.Pos, e.Message)
+}
+
+func errorAt(pos position, format string, args ...any) *Error {
+	return &Error{Pos: pos, Message: fmt.Sprintf(format, args...)}
+}
+
+// Generate writes an input generated from the spec with the seed.
+// The same seed always generates the same input.
+func (s *Spec) Generate(w io.Writer, seed int64) error {
+	g := &generator{
+		rng:  rand.New(rand.NewSource(seed)),
+		vars: map[string]int64{},
+		out:  bufio.NewWriter(w),
+	}
+	for _, l := range s.lines {
+		if err := g.line(l); err != nil {
+			return err
+		}
+	}
+
+	return g.out.Flush()
+}
+
+type generator struct {
+	rng      *rand.Rand
+	vars     map[string]int64
+	out      *bufio.Writer
+	elements int
+}
+
+func (g *generator) count(e expr, pos position, what string) (int, error) {
+	n, err := e.eval(g.vars)
+	if err != nil {
+		return 0, errorAt(pos, "%v", err)
+	}
+	if n < 0 {
+		return 0, errorAt(pos, "%s is negative: %d", what, n)
+	}
+	if n > MAX_ELEMENTS {
+		return 0, errorAt(pos, "%s is too large: %d (max %d)", what, n, MAX_ELEMENTS)
+	}
+
+	return int(n), nil
+}
+
+// use counts the generated values against MAX_ELEMENTS.
+func (g *generator) use(n int, pos position) error {
+	g.elements += n
+	if g.elements > MAX_ELEMENTS {
+		return errorAt(pos, "more than %d values are generated", MAX_ELEMENTS)
+	}
+
+	return nil
+}
+
+func (g *generator) line(l line) error {
+	repeat := 1
+	if l.repeat != nil {
+		var err error
+		if repeat, err = g.count(l.repeat, l.items[0].pos, "the repeat count"); err != nil {
+			return err
+		}
+	}
+
+	for r := 0; r < repeat; r++ {
+		if kind := l.items[0].kind; kind == "tree" || kind == "graph" {
+			if err := g.edges(l.items[0]); err != nil {
+				return err
+			}
+			continue
+		}
+		for i, it := range l.items {
+			if i > 0 {
+				g.out.WriteByte(' ')
+			}
+			if err := g.item(it); err != nil {
+				return err
+			}
+		}
+		g.out.WriteByte('\n')
+	}
+
+	return nil
+}
+
+func (g *generator) item(it item) error {
+	count := 1
+	if it.count != nil {
+		var err error
+		if count, err = g.count(it.count, it.pos, "the count"); err != nil {
+			return err
+		}
+	}
+
+	if it.kind == "perm" {
+		n, err := g.count(it.args[0], it.pos, "the size of the permutation")
+		if err != nil {
+			return err
+		}
+		for c := 0; c < count; c++ {
+			if err := g.use(n, it.pos); err != nil {
+				return err
+			}
+			if c > 0 {
+				g.out.WriteByte(' ')
+			}
+			for i, v := range g.rng.Perm(n) {
+				if i > 0 {
+					g.out.WriteByte(' ')
+				}
+				g.out.WriteString(strconv.Itoa(v + 1))
+			}
+		}
+		return nil
+	}
+
+	lo, hi, err := g.bounds(it)
+	if err != nil {
+		return err
+	}
+	if err := g.use(count, it.pos); err != nil {
+		return err
+	}
+	for c := 0; c < count; c++ {
+		if c > 0 {
+			g.out.WriteByte(' ')
+		}
+		value := g.between(lo, hi)
+		switch it.kind {
+		case "int":
+			g.out.WriteString(strconv.FormatInt(value, 10))
+			if it.name != "" && it.count == nil {
+				g.vars[it.name] = value
+			}
+		case "str":
+			if value > MAX_ELEMENTS {
+				return errorAt(it.pos, "the length is too large: %d (max %d)", value, MAX_ELEMENTS)
+			}
+			if err := g.use(int(value), it.pos); err != nil {
+				return err
+			}
+			for i := int64(0); i < value; i++ {
+				g.out.WriteByte(it.alphabet[g.rng.Intn(len(it.alphabet))])
+			}
+			if it.name != "" && it.count == nil {
+				g.vars[it.name] = value
+			}
+		}
+	}
+
+	return nil
+}
+
+func (g *generator) bounds(it item) (int64, int64, error) {
+	lo, err := it.args[0].eval(g.vars)
+	if err != nil {
+		return 0, 0, errorAt(it.pos, "%v", err)
+	}
+	hi, err := it.args[1].eval(g.vars)
+	if err != nil {
+		return 0, 0, errorAt(it.pos, "%v", err)
+	}
+	if lo > hi {
+		return 0, 0, errorAt(it.pos, "the range [%d,%d] is empty", lo, hi)
+	}
+
+	return lo, hi, nil
+}
+
+// between returns a random integer in [lo, hi].
+func (g *generator) between(lo int64, hi int64) int64 {
+	width := uint64(hi - lo)
+	if width < 1<<63-1 {
+		return lo + g.rng.Int63n(int64(width)+1)
+	}
+	if width == math.MaxUint64 {
+		// The whole range of int64, whose size does not fit in uint64.
+		return int64(g.rng.Uint64())
+	}
+
+	return lo + int64(g.rng.Uint64()%(width+1))
+}
+
+// edges writes the edges of a random tree or connected graph.
+func (g *generator) edges(it item) error {
+	n, err := g.count(it.args[0], it.pos, "the number of vertices")
+	if err != nil {
+		return err
+	}
+	m := max(n-1, 0)
+	if it.kind == "graph" {
+		if m, err = g.count(it.args[1], it.pos, "the number of edges"); err != nil {
+			return err
+		}
+		if n > 0 && m < n-1 {
+			return errorAt(it.pos, "a connected graph on %d vertices needs at least %d edges, got %d", n, n-1, m)
+		}
+		if limit := int64(n) * int64(n-1) / 2; int64(m) > limit {
+			return errorAt(it.pos, "a simple graph on %d vertices has at most %d edges, got %d", n, limit, m)
+		}
+	}
+	if err := g.use(2*m, it.pos); err != nil {
+		return err
+	}
+
+	// Attach each vertex to an earlier one in a random order, which makes a random tree.
+	labels := g.rng.Perm(n)
+	type edge struct{ u, v int }
+	edges := make([]edge, 0, m)
+	seen := make(map[edge]bool, m)
+	add := func(u int, v int) bool {
+		if u == v {
+			return false
+		}
+		key := edge{min(u, v), max(u, v)}
+		if seen[key] {
+			return false
+		}
+		seen[key] = true
+		edges = append(edges, edge{u, v})
+		return true
+	}
+	for i := 1; i < n; i++ {
+		add(labels[i], labels[g.rng.Intn(i)])
+	}
+	if 2*m <= n*(n-1)/2 {
+		for len(edges) < m {
+			add(g.rng.Intn(n), g.rng.Intn(n))
+		}
+	} else {
+		// Dense graphs: add the missing edges in a random order instead of by rejection.
+		var rest []edge
+		for u := 0; u < n; u++ {
+			for v := u + 1; v < n; v++ {
+				if !seen[edge{u, v}] {
+					rest = append(rest, edge{u, v})
+				}
+			}
+		}
+		g.rng.Shuffle(len(rest), func(i int, j int) { rest[i], rest[j] = rest[j], rest[i] })
+		for _, e := range rest[:m-len(edges)] {
+			add(e.u, e.v)
+		}
+	}
+	g.rng.Shuffle(len(edges), func(i int, j int) { edges[i], edges[j] = edges[j], edges[i] })
+
+	for _, e := range edges {
+		fmt.Fprintf(g.out, "%d %d", e.u+1, e.v+1)
+		if it.weight != nil {
+			g.out.WriteByte(' ')
+			if err := g.item(*it.weight); err != nil {
+				return err
+			}
+		}
+		g.out.WriteByte('\n')
+	}
+
+	return nil
+}
@@ -0,0 +1,184 @@
+package gen
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func generate(t *testing.T, spec string, seed int64) string {
+	t.Helper()
+	s, err := Parse(spec)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	var out bytes.Buffer
+	if err := s.Generate(&out, seed); err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+
+	return out.String()
+}
+
+func atoi(t *testing.T, s string) int {
+	t.Helper()
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		t.Fatalf("not a number: %q", s)
+	}
+
+	return n
+}
+
+func TestGenerateIsReproducibleAndInRange(t *testing.T) {
+	spec := "N:int[1,2e1] K:int[N,N*2]\nA:int[-5,5]*N\nS:str[3,3]@ab\nperm(N)"
+	for seed := int64(0); seed < 50; seed++ {
+		out := generate(t, spec, seed)
+		if again := generate(t, spec, seed); out != again {
+			t.Fatalf("seed %d is not reproducible:\n%s\n%s", seed, out, again)
+		}
+
+		lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+		if len(lines) != 4 {
+			t.Fatalf("unexpected lines:\n%s", out)
+		}
+		header := strings.Fields(lines[0])
+		n, k := atoi(t, header[0]), atoi(t, header[1])
+		if n < 1 || n > 20 || k < n || k > 2*n {
+			t.Fatalf("out of range: N=%d K=%d", n, k)
+		}
+		values := strings.Fields(lines[1])
+		if len(values) != n {
+			t.Fatalf("expected %d values: %q", n, lines[1])
+		}
+		for _, v := range values {
+			if x := atoi(t, v); x < -5 || x > 5 {
+				t.Fatalf("out of range: %d", x)
+			}
+		}
+		if len(lines[2]) != 3 || strings.Trim(lines[2], "ab") != "" {
+			t.Fatalf("unexpected string: %q", lines[2])
+		}
+		seen := map[int]bool{}
+		for _, v := range strings.Fields(lines[3]) {
+			seen[atoi(t, v)] = true
+		}
+		for i := 1; i <= n; i++ {
+			if !seen[i] || len(seen) != n {
+				t.Fatalf("not a permutation of 1..%d: %q", n, lines[3])
+			}
+		}
+	}
+}
+
+func TestGenerateFullInt64Range(t *testing.T) {
+	spec := "A:int[-9223372036854775807-1,9223372036854775807]*20"
+	for seed := int64(0); seed < 10; seed++ {
+		if out := generate(t, spec, seed); len(strings.Fields(out)) != 20 {
+			t.Fatalf("unexpected output:\n%s", out)
+		}
+	}
+}
+
+func TestGenerateConnectedSimpleGraphs(t *testing.T) {
+	for _, spec := range []string{"N:int[1,8] M:int[N-1,N-1]\ntree(N,int[1,9])", "N:int[1,8] M:int[N-1, N * (N - 1) / 2]\ngraph(N,M)"} {
+		for seed := int64(0); seed < 100; seed++ {
+			out := generate(t, spec, seed)
+			lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+			header := strings.Fields(lines[0])
+			n, m := atoi(t, header[0]), atoi(t, header[1])
+			edges := lines[1:]
+			if m == 0 {
+				edges = nil
+			}
+			if len(edges) != m {
+				t.Fatalf("expected %d edges:\n%s", m, out)
+			}
+
+			parent := make([]int, n+1)
+			for i := range parent {
+				parent[i] = i
+			}
+			var find func(int) int
+			find = func(v int) int {
+				if parent[v] != v {
+					parent[v] = find(parent[v])
+				}
+				return parent[v]
+			}
+			seen := map[[2]int]bool{}
+			for _, e := range edges {
+				fields := strings.Fields(e)
+				u, v := atoi(t, fields[0]), atoi(t, fields[1])
+				if u == v || u < 1 || v < 1 || u > n || v > n || seen[[2]int{min(u, v), max(u, v)}] {
+					t.Fatalf("invalid edge %q:\n%s", e, out)
+				}
+				seen[[2]int{min(u, v), max(u, v)}] = true
+				parent[find(u)] = find(v)
+			}
+			for v := 1; v <= n; v++ {
+				if find(v) != find(1) {
+					t.Fatalf("not connected:\n%s", out)
+				}
+			}
+		}
+	}
+}
+
+func TestGenerateRepeatedLines(t *testing.T) {
+	out := generate(t, "H:int[3,3] W:int[2,2]\n{ str[W,W]@.# }*H", 1)
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("unexpected grid:\n%s", out)
+	}
+	for _, line := range lines[1:] {
+		if len(line) != 2 || strings.Trim(line, ".#") != "" {
+			t.Fatalf("unexpected row %q", line)
+		}
+	}
+}
+
+func TestParseErrorsPointAtPosition(t *testing.T) {
+	cases := map[string]string{
+		"N:int[1,M]":              "gen spec 1:9: M is not defined before",
+		"N:int[1,5]\nA:intt[1,2]": `gen spec 2:1: unknown item "intt" (available: int, str, perm, tree, graph)`,
+		"N:int[1,5] tree(N)":      "gen spec 1:12: tree must be alone on its line",
+		"P:perm(3)":               "gen spec 1:1: only an int or a str can be named",
+		"int[1,5":                 "gen spec 1:8: expected ']', got the end of the spec",
+	}
+	for spec, want := range cases {
+		_, err := Parse(spec)
+		var specErr *Error
+		if !errors.As(err, &specErr) || err.Error() != want {
+			t.Errorf("Parse(%q) error mismatch:\nwant: %s\ngot : %v", spec, want, err)
+		}
+	}
+
+	overflows := map[string]string{
+		"N:int[5e18,5e18] M:int[N*2,N*2]":                                     "gen spec 1:18: 5000000000000000000 * 2 overflows int64",
+		"N:int[9e18,9e18]\nA:int[1,N+9e18]":                                   "gen spec 2:1: 9000000000000000000 + 9000000000000000000 overflows int64",
+		"N:int[-9e18,-9e18] M:int[N-9e18,0]":                                  "gen spec 1:20: -9000000000000000000 - 9000000000000000000 overflows int64",
+		"N:int[-9223372036854775807-1,-9223372036854775807-1] int[N/(0-1),0]": "gen spec 1:54: -9223372036854775808 / -1 overflows int64",
+	}
+	for spec, want := range overflows {
+		s, err := Parse(spec)
+		if err != nil {
+			t.Fatalf("parse failed: %v", err)
+		}
+		err = s.Generate(&bytes.Buffer{}, 0)
+		var specErr *Error
+		if !errors.As(err, &specErr) || err.Error() != want {
+			t.Errorf("Generate(%q) error mismatch:\nwant: %s\ngot : %v", spec, want, err)
+		}
+	}
+
+	s, err := Parse("N:int[5,1]")
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if err := s.Generate(&bytes.Buffer{}, 0); err == nil || err.Error() != "gen spec 1:1: the range [5,1] is empty" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
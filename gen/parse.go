@@ -0,0 +1,520 @@
+/*
+Copyright © 2024 lemolatoon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package gen
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// expr is an integer expression in a bound or a count.
+type expr interface {
+	eval(vars map[string]int64) (int64, error)
+}
+
+type number int64
+
+func (n number) eval(map[string]int64) (int64, error) {
+	return int64(n), nil
+}
+
+type variable string
+
+func (v variable) eval(vars map[string]int64) (int64, error) {
+	value, ok := vars[string(v)]
+	if !ok {
+		return 0, fmt.Errorf("%s is not defined before", v)
+	}
+
+	return value, nil
+}
+
+type binary struct {
+	op          byte
+	left, right expr
+}
+
+func (b binary) eval(vars map[string]int64) (int64, error) {
+	left, err := b.left.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	right, err := b.right.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	overflow := fmt.Errorf("%d %c %d overflows int64", left, b.op, right)
+	switch b.op {
+	case '+':
+		if (right > 0 && left > math.MaxInt64-right) || (right < 0 && left < math.MinInt64-right) {
+			return 0, overflow
+		}
+		return left + right, nil
+	case '-':
+		if (right < 0 && left > math.MaxInt64+right) || (right > 0 && left < math.MinInt64+right) {
+			return 0, overflow
+		}
+		return left - right, nil
+	case '*':
+		product := left * right
+		if left != 0 && (product/left != right || (left == -1 && right == math.MinInt64)) {
+			return 0, overflow
+		}
+		return product, nil
+	default:
+		if right == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		if left == math.MinInt64 && right == -1 {
+			return 0, overflow
+		}
+		return left / right, nil
+	}
+}
+
+type parser struct {
+	src  []rune
+	i    int
+	pos  position
+	vars map[string]bool
+	// nested is the depth of brackets, in which spaces between the operators are allowed.
+	nested int
+}
+
+// Parse parses a spec. The names used in the bounds must be defined by the earlier items.
+func Parse(spec string) (*Spec, error) {
+	p := &parser{src: []rune(spec), pos: position{line: 1, column: 1}, vars: map[string]bool{}}
+	s := &Spec{}
+	for {
+		p.skipSpaces(true)
+		if p.eof() {
+			return s, nil
+		}
+		l, err := p.line()
+		if err != nil {
+			return nil, err
+		}
+		s.lines = append(s.lines, l)
+	}
+}
+
+func (p *parser) eof() bool {
+	return p.i >= len(p.src)
+}
+
+func (p *parser) peek() rune {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.i]
+}
+
+func (p *parser) next() rune {
+	r := p.src[p.i]
+	p.i++
+	if r == '\n' {
+		p.pos.line++
+		p.pos.column = 1
+	} else {
+		p.pos.column++
+	}
+
+	return r
+}
+
+// skipSpaces skips spaces and comments starting with "#", and also line breaks and ";" if newlines is true.
+func (p *parser) skipSpaces(newlines bool) {
+	for !p.eof() {
+		switch r := p.peek(); {
+		case r == '#':
+			for !p.eof() && p.peek() != '\n' {
+				p.next()
+			}
+		case r == '\n' || r == ';':
+			if !newlines {
+				return
+			}
+			p.next()
+		case unicode.IsSpace(r):
+			p.next()
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) expect(r rune) error {
+	p.skipSpaces(false)
+	if p.peek() != r {
+		return p.unexpected(fmt.Sprintf("%q", r))
+	}
+	p.next()
+
+	return nil
+}
+
+func (p *parser) unexpected(want string) error {
+	if p.eof() {
+		return errorAt(p.pos, "expected %s, got the end of the spec", want)
+	}
+	if r := p.peek(); r == '\n' || r == ';' {
+		return errorAt(p.pos, "expected %s, got the end of the line", want)
+	}
+
+	return errorAt(p.pos, "expected %s, got %q", want, p.peek())
+}
+
+func (p *parser) ident() string {
+	start := p.i
+	for !p.eof() && (unicode.IsLetter(p.peek()) || unicode.IsDigit(p.peek()) || p.peek() == '_') {
+		p.next()
+	}
+
+	return string(p.src[start:p.i])
+}
+
+func (p *parser) line() (line, error) {
+	var l line
+	grouped := p.peek() == '{'
+	if grouped {
+		p.next()
+	}
+	for {
+		p.skipSpaces(grouped)
+		if p.eof() || p.peek() == '\n' || p.peek() == ';' || (grouped && p.peek() == '}') {
+			break
+		}
+		it, err := p.item()
+		if err != nil {
+			return line{}, err
+		}
+		l.items = append(l.items, it)
+	}
+	if len(l.items) == 0 {
+		return line{}, errorAt(p.pos, "empty line")
+	}
+	if grouped {
+		if err := p.expect('}'); err != nil {
+			return line{}, err
+		}
+		if err := p.expect('*'); err != nil {
+			return line{}, err
+		}
+		repeat, err := p.expr()
+		if err != nil {
+			return line{}, err
+		}
+		l.repeat = repeat
+	}
+	for _, it := range l.items {
+		if (it.kind == "tree" || it.kind == "graph") && len(l.items) != 1 {
+			return line{}, errorAt(it.pos, "%s must be alone on its line", it.kind)
+		}
+	}
+
+	return l, nil
+}
+
+var itemKinds = []string{"int", "str", "perm", "tree", "graph"}
+
+func (p *parser) item() (item, error) {
+	it := item{pos: p.pos}
+	word := p.ident()
+	if word == "" {
+		return item{}, p.unexpected("an item (" + strings.Join(itemKinds, ", ") + ")")
+	}
+	if p.peek() == ':' {
+		p.next()
+		it.name = word
+		word = p.ident()
+	}
+	it.kind = word
+
+	var err error
+	switch word {
+	case "int", "str":
+		if it.args, err = p.args('[', ']', 2); err != nil {
+			return item{}, err
+		}
+		if word == "str" {
+			it.alphabet = []byte("abcdefghijklmnopqrstuvwxyz")
+			if p.peek() == '@' {
+				p.next()
+				if it.alphabet, err = p.alphabet(); err != nil {
+					return item{}, err
+				}
+			}
+		}
+	case "perm":
+		if it.args, err = p.args('(', ')', 1); err != nil {
+			return item{}, err
+		}
+	case "tree", "graph":
+		if it.name != "" {
+			return item{}, errorAt(it.pos, "%s cannot be named", word)
+		}
+		if it, err = p.edgesItem(it); err != nil {
+			return item{}, err
+		}
+	default:
+		return item{}, errorAt(it.pos, "unknown item %q (available: %s)", word, strings.Join(itemKinds, ", "))
+	}
+
+	if p.peek() == '*' {
+		if word == "tree" || word == "graph" {
+			return item{}, errorAt(p.pos, "%s cannot be repeated with *; use { %s(...) }*count", word, word)
+		}
+		p.next()
+		if it.count, err = p.expr(); err != nil {
+			return item{}, err
+		}
+	}
+	if it.name != "" {
+		if word == "perm" {
+			return item{}, errorAt(it.pos, "only an int or a str can be named")
+		}
+		// The name of an array is only a label.
+		if it.count == nil {
+			p.vars[it.name] = true
+		}
+	}
+
+	return it, nil
+}
+
+// edgesItem parses "(n)" or "(n,m)" of tree and graph, optionally followed by the weight item.
+func (p *parser) edgesItem(it item) (item, error) {
+	want := 1
+	if it.kind == "graph" {
+		want = 2
+	}
+	if err := p.expect('('); err != nil {
+		return item{}, err
+	}
+	p.nested++
+	defer func() { p.nested-- }()
+	for len(it.args) < want {
+		if len(it.args) > 0 {
+			if err := p.expect(','); err != nil {
+				return item{}, err
+			}
+		}
+		e, err := p.expr()
+		if err != nil {
+			return item{}, err
+		}
+		it.args = append(it.args, e)
+	}
+	p.skipSpaces(false)
+	if p.peek() == ',' {
+		p.next()
+		p.skipSpaces(false)
+		weight, err := p.item()
+		if err != nil {
+			return item{}, err
+		}
+		if weight.kind != "int" && weight.kind != "str" {
+			return item{}, errorAt(weight.pos, "the weight must be an int or a str")
+		}
+		it.weight = &weight
+	}
+	if err := p.expect(')'); err != nil {
+		return item{}, err
+	}
+
+	return it, nil
+}
+
+func (p *parser) args(open rune, close rune, want int) ([]expr, error) {
+	if err := p.expect(open); err != nil {
+		return nil, err
+	}
+	p.nested++
+	defer func() { p.nested-- }()
+	var args []expr
+	for len(args) < want {
+		if len(args) > 0 {
+			if err := p.expect(','); err != nil {
+				return nil, err
+			}
+		}
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, e)
+	}
+	if err := p.expect(close); err != nil {
+		return nil, err
+	}
+
+	return args, nil
+}
+
+// alphabet parses the characters of a string such as "a-z0-9_", expanding the ranges.
+func (p *parser) alphabet() ([]byte, error) {
+	start := p.pos
+	var raw []rune
+	for !p.eof() && !unicode.IsSpace(p.peek()) && p.peek() != ';' && p.peek() != '}' && p.peek() != '*' && p.peek() != ')' {
+		raw = append(raw, p.next())
+	}
+
+	var alphabet []byte
+	seen := map[rune]bool{}
+	for i := 0; i < len(raw); i++ {
+		from, to := raw[i], raw[i]
+		if i+2 < len(raw) && raw[i+1] == '-' {
+			to = raw[i+2]
+			i += 2
+		}
+		if from > to || to > unicode.MaxASCII {
+			return nil, errorAt(start, "invalid alphabet %q", string(raw))
+		}
+		for r := from; r <= to; r++ {
+			if !seen[r] {
+				seen[r] = true
+				alphabet = append(alphabet, byte(r))
+			}
+		}
+	}
+	if len(alphabet) == 0 {
+		return nil, errorAt(start, "empty alphabet")
+	}
+
+	return alphabet, nil
+}
+
+// expr parses an expression of terms joined by + and -.
+func (p *parser) expr() (expr, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if p.nested > 0 {
+			p.skipSpaces(false)
+		}
+		op := p.peek()
+		if op != '+' && op != '-' {
+			return left, nil
+		}
+		p.next()
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		left = binary{op: byte(op), left: left, right: right}
+	}
+}
+
+// term parses factors joined by * and /. Out of brackets, a space ends the expression,
+// so that "int[1,9]*N M:int[1,9]" is not read as a product.
+func (p *parser) term() (expr, error) {
+	left, err := p.factor()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if p.nested > 0 {
+			p.skipSpaces(false)
+		}
+		op := p.peek()
+		if op != '*' && op != '/' {
+			return left, nil
+		}
+		p.next()
+		right, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		left = binary{op: byte(op), left: left, right: right}
+	}
+}
+
+func (p *parser) factor() (expr, error) {
+	p.skipSpaces(false)
+	pos := p.pos
+	switch r := p.peek(); {
+	case r == '(':
+		p.next()
+		p.nested++
+		defer func() { p.nested-- }()
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(')'); err != nil {
+			return nil, err
+		}
+		return e, nil
+	case r == '-':
+		p.next()
+		e, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		return binary{op: '-', left: number(0), right: e}, nil
+	case unicode.IsDigit(r):
+		return p.number()
+	case unicode.IsLetter(r) || r == '_':
+		name := p.ident()
+		if !p.vars[name] {
+			return nil, errorAt(pos, "%s is not defined before", name)
+		}
+		return variable(name), nil
+	default:
+		return nil, p.unexpected("a number or a name")
+	}
+}
+
+// number parses an integer such as "200000", "2e5" or "1_000_000".
+func (p *parser) number() (expr, error) {
+	pos := p.pos
+	start := p.i
+	for !p.eof() && (unicode.IsDigit(p.peek()) || p.peek() == '_' || p.peek() == 'e' || p.peek() == 'E') {
+		p.next()
+	}
+	text := strings.ReplaceAll(string(p.src[start:p.i]), "_", "")
+
+	mantissa, exponent, scientific := strings.Cut(strings.ToLower(text), "e")
+	value, err := strconv.ParseInt(mantissa, 10, 64)
+	if err != nil {
+		return nil, errorAt(pos, "invalid number %q", text)
+	}
+	if scientific {
+		e, err := strconv.Atoi(exponent)
+		if err != nil || e > 18 {
+			return nil, errorAt(pos, "invalid number %q", text)
+		}
+		scale := int64(math.Pow10(e))
+		if value > math.MaxInt64/scale {
+			return nil, errorAt(pos, "number %q is too large", text)
+		}
+		value *= scale
+	}
+
+	return number(value), nil
+}
@@ -0,0 +1,196 @@
+/*
+Copyright © 2024 lemolatoon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Package history keeps a log of the events of solving the problems of a contest, such as
+// creating a problem, running its tests and submitting it, as JSON lines in the contest
+// directory, and summarizes it for retrospectives.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// LOG_FILE is the log in the contest directory, one JSON object per line.
+const LOG_FILE = ".acutils-history.jsonl"
+
+// Kind is the kind of an event.
+type Kind string
+
+const (
+	EVENT_NEW  Kind = "new"
+	EVENT_RUN  Kind = "run"
+	EVENT_TEST Kind = "test"
+	EVENT_CLIP Kind = "clip"
+	// EVENT_SUBMIT is a submission to the judge, with the verdict of the judge.
+	EVENT_SUBMIT Kind = "submit"
+)
+
+// Event is a line of the log.
+type Event struct {
+	Time    time.Time `json:"time"`
+	Kind    Kind      `json:"event"`
+	Problem string    `json:"problem"`
+	// Verdict is the verdict of the run, the test or the submission, e.g. "AC", "RE" or "CE".
+	Verdict string `json:"verdict,omitempty"`
+	// Verdicts are the numbers of the cases of a test for each verdict.
+	Verdicts map[string]int `json:"verdicts,omitempty"`
+}
+
+// Path returns the path to the log of the contest directory.
+func Path(contestDirectory string) string {
+	return filepath.Join(contestDirectory, LOG_FILE)
+}
+
+// Append appends the event to the log of the contest directory, creating it if needed.
+func Append(contestDirectory string, event Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(Path(contestDirectory), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	// A single write keeps the lines whole when several commands append at once.
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// Read reads the events of a log. Empty lines are skipped.
+func Read(r io.Reader) ([]Event, error) {
+	var events []Event
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		events = append(events, event)
+	}
+
+	return events, scanner.Err()
+}
+
+// Load reads the log of the contest directory.
+func Load(contestDirectory string) ([]Event, error) {
+	f, err := os.Open(Path(contestDirectory))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	events, err := Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", Path(contestDirectory), err)
+	}
+
+	return events, nil
+}
+
+// ProblemStats is the summary of the events of a problem.
+type ProblemStats struct {
+	Problem string
+	// Started is the time of the new event, or of the first event if there is none.
+	Started time.Time
+	// FirstAC is the time of the first accepted submission, or of the first test in which
+	// all the cases passed if no submission is accepted. It is zero if there is neither.
+	FirstAC time.Time
+	// LocalAC is true if FirstAC is the time of a test.
+	LocalAC bool
+	Runs    int
+	Tests   int
+	Clips   int
+	// Rejected is the number of submissions which were not accepted, except CE.
+	Rejected int
+}
+
+// TimeToFirstAC returns the time from the start to the first AC, or 0 if there is none.
+func (s ProblemStats) TimeToFirstAC() time.Duration {
+	if s.FirstAC.IsZero() {
+		return 0
+	}
+
+	return s.FirstAC.Sub(s.Started)
+}
+
+// Summarize summarizes the events for each problem, ordered by the start of the problems.
+func Summarize(events []Event) []ProblemStats {
+	events = append([]Event(nil), events...)
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+
+	stats := map[string]*ProblemStats{}
+	var problems []*ProblemStats
+	for _, event := range events {
+		s, ok := stats[event.Problem]
+		if !ok {
+			s = &ProblemStats{Problem: event.Problem, Started: event.Time}
+			stats[event.Problem] = s
+			problems = append(problems, s)
+		}
+		switch event.Kind {
+		case EVENT_NEW:
+			s.Started = event.Time
+		case EVENT_RUN:
+			s.Runs++
+		case EVENT_TEST:
+			s.Tests++
+			if event.Verdict == "AC" && s.FirstAC.IsZero() {
+				s.FirstAC, s.LocalAC = event.Time, true
+			}
+		case EVENT_CLIP:
+			s.Clips++
+		case EVENT_SUBMIT:
+			switch event.Verdict {
+			case "AC":
+				if s.FirstAC.IsZero() || s.LocalAC {
+					s.FirstAC, s.LocalAC = event.Time, false
+				}
+			case "CE":
+			default:
+				s.Rejected++
+			}
+		}
+	}
+
+	summaries := make([]ProblemStats, len(problems))
+	for i, s := range problems {
+		summaries[i] = *s
+	}
+	sort.SliceStable(summaries, func(i, j int) bool { return summaries[i].Started.Before(summaries[j].Started) })
+
+	return summaries
+}
@@ -0,0 +1,88 @@
+/*
+Copyright © 2024 lemolatoon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package history
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppendAndLoad(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2024, 3, 2, 21, 0, 0, 0, time.UTC)
+	events := []Event{
+		{Time: start, Kind: EVENT_NEW, Problem: "a"},
+		{Time: start.Add(time.Minute), Kind: EVENT_TEST, Problem: "a", Verdict: "WA", Verdicts: map[string]int{"AC": 2, "WA": 1}},
+	}
+	for _, event := range events {
+		if err := Append(dir, event); err != nil {
+			t.Fatalf("append failed: %v", err)
+		}
+	}
+
+	got, err := Load(dir)
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if len(got) != 2 || !got[1].Time.Equal(events[1].Time) || got[1].Verdicts["WA"] != 1 || got[0].Kind != EVENT_NEW {
+		t.Fatalf("unexpected events: %+v", got)
+	}
+}
+
+func TestReadReportsLineOfMalformedEvent(t *testing.T) {
+	_, err := Read(strings.NewReader("{\"event\":\"new\",\"problem\":\"a\"}\n\n{broken\n"))
+	if err == nil || !strings.HasPrefix(err.Error(), "line 3:") {
+		t.Fatalf("expected an error at line 3, got %v", err)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	start := time.Date(2024, 3, 2, 21, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+	events := []Event{
+		{Time: at(0), Kind: EVENT_NEW, Problem: "a"},
+		{Time: at(1), Kind: EVENT_NEW, Problem: "b"},
+		{Time: at(2), Kind: EVENT_RUN, Problem: "a", Verdict: "OK"},
+		{Time: at(3), Kind: EVENT_TEST, Problem: "a", Verdict: "AC"},
+		{Time: at(4), Kind: EVENT_CLIP, Problem: "a"},
+		{Time: at(5), Kind: EVENT_SUBMIT, Problem: "a", Verdict: "WA"},
+		{Time: at(6), Kind: EVENT_SUBMIT, Problem: "a", Verdict: "CE"},
+		{Time: at(9), Kind: EVENT_SUBMIT, Problem: "a", Verdict: "AC"},
+		{Time: at(12), Kind: EVENT_TEST, Problem: "b", Verdict: "WA"},
+		{Time: at(21), Kind: EVENT_TEST, Problem: "b", Verdict: "AC"},
+		// The log may have the events of commands run at once out of order.
+		{Time: at(7), Kind: EVENT_TEST, Problem: "a", Verdict: "AC"},
+	}
+
+	stats := Summarize(events)
+	if len(stats) != 2 || stats[0].Problem != "a" || stats[1].Problem != "b" {
+		t.Fatalf("unexpected problems: %+v", stats)
+	}
+	a, b := stats[0], stats[1]
+	if a.TimeToFirstAC() != 9*time.Minute || a.LocalAC || a.Runs != 1 || a.Tests != 2 || a.Clips != 1 || a.Rejected != 1 {
+		t.Fatalf("unexpected stats of a: %+v", a)
+	}
+	if b.TimeToFirstAC() != 20*time.Minute || !b.LocalAC || b.Tests != 2 || b.Rejected != 0 {
+		t.Fatalf("unexpected stats of b: %+v", b)
+	}
+}
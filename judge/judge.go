@@ -0,0 +1,326 @@
+/*
+Copyright © 2024 lemolatoon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package judge
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/lemolatoon/acutils-cli/sandbox"
+)
+
+// TESTS_DIR is the directory in a problem directory which has the test cases.
+// A test case is a pair of NAME.in and NAME.out, and NAME.out may be missing.
+const TESTS_DIR = "tests"
+
+// CUSTOM_PREFIX is the prefix of the names of the cases added by hand, e.g. "custom-1".
+const CUSTOM_PREFIX = "custom-"
+
+// Case is a test case.
+type Case struct {
+	Name   string
+	Input  string
+	Output string
+}
+
+// HasOutput reports whether the case has the expected output.
+func (c Case) HasOutput() bool {
+	return c.Output != ""
+}
+
+// Discover returns the test cases in the tests directory of the problem directory,
+// ordered so that "sample-2" comes before "sample-10".
+func Discover(problemDirectory string) ([]Case, error) {
+	inputs, err := filepath.Glob(filepath.Join(problemDirectory, TESTS_DIR, "*.in"))
+	if err != nil {
+		return nil, err
+	}
+
+	cases := make([]Case, 0, len(inputs))
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".in")
+		c := Case{Name: name, Input: input}
+		output := strings.TrimSuffix(input, ".in") + ".out"
+		if _, err := os.Stat(output); err == nil {
+			c.Output = output
+		}
+		cases = append(cases, c)
+	}
+	sort.Slice(cases, func(i, j int) bool {
+		return naturalLess(cases[i].Name, cases[j].Name)
+	})
+
+	return cases, nil
+}
+
+// NextCustomCase returns the case following the last custom case of the problem directory,
+// e.g. custom-3 after custom-2. The files of the case do not exist yet.
+func NextCustomCase(problemDirectory string) (Case, error) {
+	cases, err := Discover(problemDirectory)
+	if err != nil {
+		return Case{}, err
+	}
+	next := 1
+	for _, c := range cases {
+		if n, err := strconv.Atoi(strings.TrimPrefix(c.Name, CUSTOM_PREFIX)); err == nil && strings.HasPrefix(c.Name, CUSTOM_PREFIX) {
+			next = max(next, n+1)
+		}
+	}
+
+	return NewCase(problemDirectory, fmt.Sprintf("%s%d", CUSTOM_PREFIX, next)), nil
+}
+
+// NewCase returns the case with the name in the problem directory, whether its files exist or not.
+func NewCase(problemDirectory string, name string) Case {
+	base := filepath.Join(problemDirectory, TESTS_DIR, name)
+
+	return Case{Name: name, Input: base + ".in", Output: base + ".out"}
+}
+
+// naturalLess compares strings treating runs of digits as numbers.
+func naturalLess(a string, b string) bool {
+	for a != "" && b != "" {
+		chunkA, restA := nextChunk(a)
+		chunkB, restB := nextChunk(b)
+		if chunkA != chunkB {
+			numberA, errA := strconv.Atoi(chunkA)
+			numberB, errB := strconv.Atoi(chunkB)
+			if errA == nil && errB == nil && numberA != numberB {
+				return numberA < numberB
+			}
+			return chunkA < chunkB
+		}
+		a, b = restA, restB
+	}
+
+	return len(a) < len(b)
+}
+
+func nextChunk(s string) (string, string) {
+	digit := unicode.IsDigit(rune(s[0]))
+	i := 1
+	for i < len(s) && unicode.IsDigit(rune(s[i])) == digit {
+		i++
+	}
+
+	return s[:i], s[i:]
+}
+
+// Result is the result of running a solution against a test case.
+type Result struct {
+	Case    Case
+	Verdict Verdict
+	Time    time.Duration
+	// Memory is the peak resident set size in bytes, or 0 if it is unknown.
+	Memory int64
+	Stdout []byte
+	// Expected is the content of the expected output, if the case has one.
+	Expected  []byte
+	Execution Execution
+}
+
+// RunCase runs the executable in the sandbox with the input of the case and judges its output.
+// Exceeding the CPU time limit is judged as TLE and the memory limit as MLE.
+func RunCase(executable string, c Case, timeLimit time.Duration, limits sandbox.Limits) (Result, error) {
+	result := Result{Case: c}
+	input, err := os.Open(c.Input)
+	if err != nil {
+		return result, err
+	}
+	defer input.Close()
+	if c.HasOutput() {
+		if result.Expected, err = os.ReadFile(c.Output); err != nil {
+			return result, err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeLimit)
+	defer cancel()
+	cmd := sandbox.Command(ctx, limits, executable)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = input
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	start := time.Now()
+	runErr := cmd.Run()
+	result.Time = time.Since(start)
+	result.Memory = cmd.MaxRSS()
+	result.Stdout = stdout.Bytes()
+	result.Execution = NewExecution(runErr, stderr.Bytes())
+
+	var execErr *exec.Error
+	switch {
+	case errors.As(runErr, &execErr):
+		return result, runErr
+	case errors.Is(ctx.Err(), context.DeadlineExceeded), result.Execution.Limit == sandbox.LIMIT_CPU:
+		result.Verdict = TLE
+	case result.Execution.Limit == sandbox.LIMIT_MEMORY:
+		result.Verdict = MLE
+	case result.Execution.RuntimeError():
+		result.Verdict = RE
+	case !c.HasOutput():
+		result.Verdict = OK
+	case Equal(result.Expected, result.Stdout):
+		result.Verdict = AC
+	default:
+		result.Verdict = WA
+	}
+
+	return result, nil
+}
+
+// Options are the options to run the cases with.
+type Options struct {
+	TimeLimit time.Duration
+	Limits    sandbox.Limits
+	// Jobs is the number of cases run at once. Values less than 1 mean 1.
+	Jobs int
+}
+
+// RunCases runs the executable against the cases on a pool of opts.Jobs workers.
+// The results are in the order of the cases, and report, if not nil, is called with
+// each of them in that order as soon as the preceding cases are done.
+//
+// Running cases at once slows each of them down, so the cases judged as TLE in
+// parallel are run again alone before they are reported.
+func RunCases(executable string, cases []Case, opts Options, report func(Result)) ([]Result, error) {
+	jobs := max(1, min(opts.Jobs, len(cases)))
+	results := make([]Result, len(cases))
+	errs := make([]error, len(cases))
+	done := make([]chan struct{}, len(cases))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+
+	indexes := make(chan int)
+	stop := make(chan struct{})
+	// The workers hold the read lock while running a case, so that holding the write
+	// lock runs a case alone.
+	var alone sync.RWMutex
+	var wg sync.WaitGroup
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				alone.RLock()
+				results[i], errs[i] = RunCase(executable, cases[i], opts.TimeLimit, opts.Limits)
+				alone.RUnlock()
+				close(done[i])
+			}
+		}()
+	}
+	go func() {
+		defer close(indexes)
+		for i := range cases {
+			select {
+			case indexes <- i:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	defer wg.Wait()
+
+	for i := range cases {
+		<-done[i]
+		if errs[i] != nil {
+			close(stop)
+			return nil, errs[i]
+		}
+		if jobs > 1 && results[i].Verdict == TLE {
+			alone.Lock()
+			rerun, err := RunCase(executable, cases[i], opts.TimeLimit, opts.Limits)
+			alone.Unlock()
+			if err != nil {
+				close(stop)
+				return nil, err
+			}
+			results[i] = rerun
+		}
+		if report != nil {
+			report(results[i])
+		}
+	}
+
+	return results, nil
+}
+
+// Equal compares outputs token by token, ignoring differences in whitespace.
+func Equal(expected []byte, actual []byte) bool {
+	expectedTokens := bytes.Fields(expected)
+	actualTokens := bytes.Fields(actual)
+	if len(expectedTokens) != len(actualTokens) {
+		return false
+	}
+	for i := range expectedTokens {
+		if !bytes.Equal(expectedTokens[i], actualTokens[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Summary counts the verdicts of the results.
+type Summary map[Verdict]int
+
+func Summarize(results []Result) Summary {
+	summary := Summary{}
+	for _, result := range results {
+		summary[result.Verdict]++
+	}
+
+	return summary
+}
+
+// Passed reports whether no case is failed.
+// Cases without the expected output pass if they run without errors.
+func (s Summary) Passed() bool {
+	return s[WA] == 0 && s[RE] == 0 && s[TLE] == 0 && s[MLE] == 0
+}
+
+// Verdict returns the overall verdict, e.g. WA if any case is WA and no case is RE, MLE or TLE.
+func (s Summary) Verdict() Verdict {
+	for _, verdict := range []Verdict{RE, MLE, TLE, WA} {
+		if s[verdict] != 0 {
+			return verdict
+		}
+	}
+	if s[AC] != 0 {
+		return AC
+	}
+
+	return OK
+}
@@ -0,0 +1,265 @@
+package judge
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lemolatoon/acutils-cli/sandbox"
+)
+
+func writeFile(t *testing.T, path string, content string, mode os.FileMode) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), mode); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
+
+func TestDiscoverOrdersCasesNaturally(t *testing.T) {
+	tmp := t.TempDir()
+	for _, name := range []string{"sample-10", "sample-2", "custom-1", "sample-1"} {
+		writeFile(t, filepath.Join(tmp, TESTS_DIR, name+".in"), "", 0o644)
+	}
+	writeFile(t, filepath.Join(tmp, TESTS_DIR, "sample-1.out"), "", 0o644)
+
+	cases, err := Discover(tmp)
+	if err != nil {
+		t.Fatalf("discover failed: %v", err)
+	}
+	var names []string
+	for _, c := range cases {
+		names = append(names, c.Name)
+	}
+	want := []string{"custom-1", "sample-1", "sample-2", "sample-10"}
+	if len(names) != len(want) {
+		t.Fatalf("case mismatch:\nwant: %v\ngot : %v", want, names)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("case order mismatch:\nwant: %v\ngot : %v", want, names)
+		}
+	}
+	if !cases[1].HasOutput() || cases[2].HasOutput() {
+		t.Fatalf("unexpected expected outputs: %+v", cases)
+	}
+}
+
+func TestEqualIgnoresWhitespace(t *testing.T) {
+	if !Equal([]byte("1 2\n3\n"), []byte("1  2 3")) {
+		t.Fatalf("expected outputs to be equal")
+	}
+	if Equal([]byte("1 2\n"), []byte("1 2 3\n")) {
+		t.Fatalf("expected outputs to differ")
+	}
+}
+
+func TestRunCaseVerdicts(t *testing.T) {
+	tmp := t.TempDir()
+	solution := filepath.Join(tmp, "a.out")
+	writeFile(t, solution, "#!/bin/sh\nread n\nif [ \"$n\" = loop ]; then sleep 5; fi\nif [ \"$n\" = crash ]; then exit 3; fi\necho \"$n\"\n", 0o755)
+
+	cases := []struct {
+		input    string
+		expected string
+		want     Verdict
+	}{
+		{"1", "1", AC},
+		{"2", "3", WA},
+		{"crash", "", RE},
+		{"loop", "", TLE},
+	}
+	for _, c := range cases {
+		writeFile(t, filepath.Join(tmp, TESTS_DIR, c.input+".in"), c.input+"\n", 0o644)
+		testCase := Case{Name: c.input, Input: filepath.Join(tmp, TESTS_DIR, c.input+".in")}
+		if c.expected != "" {
+			testCase.Output = filepath.Join(tmp, TESTS_DIR, c.input+".out")
+			writeFile(t, testCase.Output, c.expected+"\n", 0o644)
+		}
+
+		result, err := RunCase(solution, testCase, 500*time.Millisecond, sandbox.Limits{})
+		if err != nil {
+			t.Fatalf("run failed: %v", err)
+		}
+		if result.Verdict != c.want {
+			t.Fatalf("verdict mismatch for %q: want %s, got %s", c.input, c.want, result.Verdict)
+		}
+	}
+}
+
+func TestRunCasesReportsInCaseOrder(t *testing.T) {
+	tmp := t.TempDir()
+	solution := filepath.Join(tmp, "a.out")
+	// Earlier cases take longer, so that they finish last when run in parallel.
+	writeFile(t, solution, "#!/bin/sh\nread n\nsleep \"0.$n\"\necho \"$n\"\n", 0o755)
+
+	var cases []Case
+	for _, n := range []string{"4", "3", "2", "1"} {
+		c := Case{Name: n, Input: filepath.Join(tmp, TESTS_DIR, n+".in"), Output: filepath.Join(tmp, TESTS_DIR, n+".out")}
+		writeFile(t, c.Input, n+"\n", 0o644)
+		writeFile(t, c.Output, n+"\n", 0o644)
+		cases = append(cases, c)
+	}
+
+	var reported []string
+	start := time.Now()
+	results, err := RunCases(solution, cases, Options{TimeLimit: 2 * time.Second, Jobs: 4}, func(result Result) {
+		reported = append(reported, result.Case.Name)
+	})
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	want := []string{"4", "3", "2", "1"}
+	if len(reported) != len(want) || len(results) != len(want) {
+		t.Fatalf("unexpected results: %v", reported)
+	}
+	for i := range want {
+		if reported[i] != want[i] || results[i].Case.Name != want[i] || results[i].Verdict != AC {
+			t.Fatalf("results out of order or failed: %v %+v", reported, results)
+		}
+	}
+	if elapsed >= time.Second {
+		t.Fatalf("expected the cases to run in parallel, took %s", elapsed)
+	}
+}
+
+func TestWriteJSONAndJUnit(t *testing.T) {
+	results := []Result{
+		{Case: Case{Name: "sample-1", Input: "a/tests/sample-1.in", Output: "a/tests/sample-1.out"}, Verdict: AC, Time: 3 * time.Millisecond, Memory: 4 << 20, Expected: []byte("1\n"), Stdout: []byte("1\n")},
+		{Case: Case{Name: "sample-2", Input: "a/tests/sample-2.in", Output: "a/tests/sample-2.out"}, Verdict: WA, Time: 2 * time.Millisecond, Expected: []byte("1 2\n"), Stdout: []byte("1 3\n")},
+		{Case: Case{Name: "custom-1", Input: "a/tests/custom-1.in"}, Verdict: TLE, Time: 2 * time.Second},
+	}
+
+	var out bytes.Buffer
+	if err := WriteJSON(&out, "a", "a/main.cpp", results); err != nil {
+		t.Fatalf("json failed: %v", err)
+	}
+	var report struct {
+		Verdict Verdict
+		Passed  int
+		Total   int
+		Cases   []map[string]any
+	}
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatalf("invalid json: %v\n%s", err, out.String())
+	}
+	if report.Verdict != TLE || report.Passed != 1 || report.Total != 3 || len(report.Cases) != 3 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if report.Cases[0]["memory_bytes"] != float64(4<<20) || report.Cases[0]["time_ms"] != float64(3) {
+		t.Fatalf("unexpected measurements: %+v", report.Cases[0])
+	}
+	if detail, _ := report.Cases[1]["detail"].(string); !strings.Contains(detail, "-1 2\n+1 3\n") {
+		t.Fatalf("expected a diff excerpt for WA: %q", detail)
+	}
+
+	out.Reset()
+	if err := WriteJUnit(&out, "a", "a/main.cpp", results); err != nil {
+		t.Fatalf("junit failed: %v", err)
+	}
+	var suites struct {
+		Suites []struct {
+			Tests    int `xml:"tests,attr"`
+			Failures int `xml:"failures,attr"`
+			Errors   int `xml:"errors,attr"`
+			Cases    []struct {
+				Name    string `xml:"name,attr"`
+				Failure *struct {
+					Type string `xml:"type,attr"`
+					Text string `xml:",chardata"`
+				} `xml:"failure"`
+				Error *struct {
+					Type string `xml:"type,attr"`
+				} `xml:"error"`
+			} `xml:"testcase"`
+		} `xml:"testsuite"`
+	}
+	if err := xml.Unmarshal(out.Bytes(), &suites); err != nil {
+		t.Fatalf("invalid xml: %v\n%s", err, out.String())
+	}
+	if len(suites.Suites) != 1 {
+		t.Fatalf("unexpected suites: %s", out.String())
+	}
+	suite := suites.Suites[0]
+	if suite.Tests != 3 || suite.Failures != 1 || suite.Errors != 1 {
+		t.Fatalf("unexpected counts: %s", out.String())
+	}
+	if c := suite.Cases[1]; c.Failure == nil || c.Failure.Type != "WA" || !strings.Contains(c.Failure.Text, "+1 3") {
+		t.Fatalf("expected WA to be a failure with the diff: %s", out.String())
+	}
+	if c := suite.Cases[2]; c.Error == nil || c.Error.Type != "TLE" {
+		t.Fatalf("expected TLE to be an error: %s", out.String())
+	}
+}
+
+func TestSplitterSplitsCases(t *testing.T) {
+	lines, err := ParseSplitter("lines:2")
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	cases, err := lines.Split([]byte("2\n3\n1 2 3\n1\n5\n"))
+	if err != nil {
+		t.Fatalf("split failed: %v", err)
+	}
+	if len(cases) != 2 || string(cases[0]) != "1\n3\n1 2 3\n" || string(cases[1]) != "1\n1\n5\n" {
+		t.Fatalf("unexpected cases: %q", cases)
+	}
+
+	header, err := ParseSplitter("header:2")
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	cases, err = header.Split([]byte("2\n3 2\n1 2\n2 3\n2 0\n"))
+	if err != nil {
+		t.Fatalf("split failed: %v", err)
+	}
+	if len(cases) != 2 || string(cases[0]) != "1\n3 2\n1 2\n2 3\n" || string(cases[1]) != "1\n2 0\n" {
+		t.Fatalf("unexpected cases: %q", cases)
+	}
+
+	if _, err := lines.Split([]byte("3\n1\n5\n")); err == nil {
+		t.Fatalf("expected an error for a short input")
+	}
+	for _, spec := range []string{"", "lines", "lines:0", "rows:2"} {
+		if _, err := ParseSplitter(spec); err == nil {
+			t.Errorf("ParseSplitter(%q) should fail", spec)
+		}
+	}
+}
+
+func TestRunSubcasesFindsFailingCase(t *testing.T) {
+	tmp := t.TempDir()
+	// Prints each number doubled, but 7 tripled.
+	solution := filepath.Join(tmp, "a.out")
+	writeFile(t, solution, "#!/bin/sh\nread t\nwhile [ \"$t\" -gt 0 ]; do read x; if [ \"$x\" = 7 ]; then echo $((x*3)); else echo $((x*2)); fi; t=$((t-1)); done\n", 0o755)
+	reference := filepath.Join(tmp, "naive.out")
+	writeFile(t, reference, "#!/bin/sh\nread t\nwhile [ \"$t\" -gt 0 ]; do read x; echo $((x*2)); t=$((t-1)); done\n", 0o755)
+
+	c := Case{Name: "1", Input: filepath.Join(tmp, TESTS_DIR, "1.in"), Output: filepath.Join(tmp, TESTS_DIR, "1.out")}
+	writeFile(t, c.Input, "3\n1\n7\n2\n", 0o644)
+	writeFile(t, c.Output, "2\n14\n4\n", 0o644)
+	failed, err := RunCase(solution, c, 2*time.Second, sandbox.Limits{})
+	if err != nil || failed.Verdict != WA {
+		t.Fatalf("expected WA: %v %+v", err, failed)
+	}
+
+	opts := Options{TimeLimit: 2 * time.Second}
+	for _, ref := range []string{"", reference} {
+		report, err := RunSubcases(solution, ref, failed, Splitter{Lines: 1}, opts, t.TempDir())
+		if err != nil {
+			t.Fatalf("run subcases failed: %v", err)
+		}
+		if report.Index != 2 || report.Count != 3 || report.Result.Verdict != WA ||
+			string(report.Input()) != "1\n7\n" || !Equal(report.Result.Expected, []byte("14")) {
+			t.Fatalf("unexpected report with reference %q: %+v", ref, report)
+		}
+	}
+}
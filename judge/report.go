@@ -0,0 +1,181 @@
+/*
+Copyright © 2024 lemolatoon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package judge
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/lemolatoon/acutils-cli/diff"
+)
+
+// Format is the format of the test results.
+type Format string
+
+const (
+	FORMAT_TEXT  Format = "text"
+	FORMAT_JSON  Format = "json"
+	FORMAT_JUNIT Format = "junit"
+)
+
+var FORMATS = []Format{FORMAT_TEXT, FORMAT_JSON, FORMAT_JUNIT}
+
+// Detail returns the details of a failed case in plain text: the diff excerpt of WA,
+// the sanitizer headline or the error of the run. It is "" for passed cases.
+func (r Result) Detail(sourceFilePath string) string {
+	switch {
+	case r.Verdict == WA:
+		var b strings.Builder
+		_ = diff.Write(&b, r.Expected, r.Stdout, diff.DefaultOptions)
+		return b.String()
+	case r.Execution.Report != nil:
+		return r.Execution.Report.Headline(sourceFilePath)
+	case (r.Verdict == RE || r.Verdict == MLE) && r.Execution.Err != nil:
+		return r.Execution.Err.Error()
+	case r.Verdict == TLE:
+		return "time limit exceeded"
+	}
+
+	return ""
+}
+
+type jsonReport struct {
+	Problem string     `json:"problem"`
+	Verdict Verdict    `json:"verdict"`
+	Passed  int        `json:"passed"`
+	Total   int        `json:"total"`
+	Cases   []jsonCase `json:"cases"`
+}
+
+type jsonCase struct {
+	Name    string  `json:"name"`
+	Verdict Verdict `json:"verdict"`
+	TimeMS  float64 `json:"time_ms"`
+	// MemoryBytes is omitted when the memory usage is unknown.
+	MemoryBytes int64  `json:"memory_bytes,omitempty"`
+	Input       string `json:"input"`
+	Output      string `json:"output,omitempty"`
+	Detail      string `json:"detail,omitempty"`
+}
+
+// WriteJSON writes the results of the problem as a JSON object.
+func WriteJSON(w io.Writer, problem string, sourceFilePath string, results []Result) error {
+	summary := Summarize(results)
+	report := jsonReport{
+		Problem: problem,
+		Verdict: summary.Verdict(),
+		Passed:  summary[AC] + summary[OK],
+		Total:   len(results),
+		Cases:   make([]jsonCase, len(results)),
+	}
+	for i, result := range results {
+		report.Cases[i] = jsonCase{
+			Name:        result.Case.Name,
+			Verdict:     result.Verdict,
+			TimeMS:      float64(result.Time.Microseconds()) / 1000,
+			MemoryBytes: result.Memory,
+			Input:       result.Case.Input,
+			Output:      result.Case.Output,
+			Detail:      result.Detail(sourceFilePath),
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",cdata"`
+}
+
+// WriteJUnit writes the results of the problem as a JUnit XML test suite.
+// WA is reported as a failure, and RE, TLE and MLE as errors.
+func WriteJUnit(w io.Writer, problem string, sourceFilePath string, results []Result) error {
+	suite := junitTestSuite{Name: problem, Tests: len(results)}
+	var total time.Duration
+	for _, result := range results {
+		total += result.Time
+		testCase := junitTestCase{Name: result.Case.Name, ClassName: problem, Time: junitSeconds(result.Time)}
+		detail := result.Detail(sourceFilePath)
+		problem := &junitProblem{Message: firstLine(detail), Type: string(result.Verdict), Text: detail}
+		switch result.Verdict {
+		case WA:
+			testCase.Failure = problem
+			suite.Failures++
+		case RE, TLE, MLE:
+			testCase.Error = problem
+			suite.Errors++
+		}
+		suite.Cases = append(suite.Cases, testCase)
+	}
+	suite.Time = junitSeconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(junitTestSuites{Suites: []junitTestSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
@@ -0,0 +1,218 @@
+/*
+Copyright © 2024 lemolatoon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package judge
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Splitter splits an input of "T test cases", whose first line is T, into the inputs of
+// the single cases, so that a failed case can be narrowed down to one of them.
+type Splitter struct {
+	// Lines is the number of lines of each case, if it is fixed.
+	Lines int
+	// HeaderField is the 1-based field of the first line of each case which is the number
+	// of the following lines of the case, e.g. 2 for "N M" followed by M edges.
+	HeaderField int
+}
+
+// ParseSplitter parses a format hint: "lines:K" for cases of K lines, or "header:I" for
+// cases of a header line followed by as many lines as the I-th number in the header.
+func ParseSplitter(spec string) (Splitter, error) {
+	kind, value, _ := strings.Cut(spec, ":")
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return Splitter{}, fmt.Errorf(`invalid split format %q (e.g. "lines:2", "header:1")`, spec)
+	}
+	switch kind {
+	case "lines":
+		return Splitter{Lines: n}, nil
+	case "header":
+		return Splitter{HeaderField: n}, nil
+	default:
+		return Splitter{}, fmt.Errorf(`invalid split format %q (e.g. "lines:2", "header:1")`, spec)
+	}
+}
+
+// Split returns the inputs of the cases, each of which starts with "1" as the number of cases.
+func (s Splitter) Split(input []byte) ([][]byte, error) {
+	lines := strings.Split(strings.TrimRight(string(input), "\n"), "\n")
+	t, err := strconv.Atoi(strings.TrimSpace(lines[0]))
+	if err != nil || t <= 0 {
+		return nil, fmt.Errorf("the first line %q is not the number of cases", lines[0])
+	}
+
+	cases := make([][]byte, 0, t)
+	rest := lines[1:]
+	for i := 1; i <= t; i++ {
+		length := s.Lines
+		if s.HeaderField > 0 {
+			if len(rest) == 0 {
+				return nil, fmt.Errorf("the input ends before case %d of %d", i, t)
+			}
+			fields := strings.Fields(rest[0])
+			if len(fields) < s.HeaderField {
+				return nil, fmt.Errorf("case %d: the header %q has no field %d", i, rest[0], s.HeaderField)
+			}
+			n, err := strconv.Atoi(fields[s.HeaderField-1])
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("case %d: field %d of the header %q is not a number of lines", i, s.HeaderField, rest[0])
+			}
+			length = 1 + n
+		}
+		if len(rest) < length {
+			return nil, fmt.Errorf("the input ends before case %d of %d", i, t)
+		}
+		cases = append(cases, []byte("1\n"+strings.Join(rest[:length], "\n")+"\n"))
+		rest = rest[length:]
+	}
+	if len(strings.TrimSpace(strings.Join(rest, "\n"))) != 0 {
+		return nil, fmt.Errorf("%d lines are left after %d cases; is the format right?", len(rest), t)
+	}
+
+	return cases, nil
+}
+
+// SubcaseReport is the result of running the cases of a failed input one by one.
+type SubcaseReport struct {
+	// Index is the 1-based index of the first failing case, or 0 if every case passes alone.
+	Index int
+	Count int
+	// Result is the result of the failing case alone. Its Expected is the output of the
+	// reference solution, or the part of the expected output for the case.
+	Result Result
+}
+
+// PassesAlone reports whether every case passed when run alone, which suggests that a state
+// is not reset between the cases.
+func (r *SubcaseReport) PassesAlone() bool {
+	return r.Index == 0
+}
+
+// Input returns the input of the failing case.
+func (r *SubcaseReport) Input() []byte {
+	input, _ := os.ReadFile(r.Result.Case.Input)
+	return input
+}
+
+// RunSubcases splits the input of the failed result and runs its cases one by one to find
+// the first failing one. The outputs are compared with the ones of the reference executable
+// if it is given, or else with the expected output of the result.
+// The inputs of the cases are written to dir.
+func RunSubcases(executable string, reference string, failed Result, splitter Splitter, opts Options, dir string) (*SubcaseReport, error) {
+	if reference == "" && !failed.Case.HasOutput() {
+		return nil, errors.New("neither a reference solution nor the expected output is available")
+	}
+	input, err := os.ReadFile(failed.Case.Input)
+	if err != nil {
+		return nil, err
+	}
+	inputs, err := splitter.Split(input)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &SubcaseReport{Count: len(inputs)}
+	results := make([]Result, 0, len(inputs))
+	for i, subInput := range inputs {
+		c := Case{Name: fmt.Sprintf("%s#%d", failed.Case.Name, i+1), Input: filepath.Join(dir, fmt.Sprintf("%s.%d.in", failed.Case.Name, i+1))}
+		if err := os.WriteFile(c.Input, subInput, 0644); err != nil {
+			return nil, err
+		}
+		result, err := RunCase(executable, c, opts.TimeLimit, opts.Limits)
+		if err != nil {
+			return nil, err
+		}
+		if result.Verdict != OK {
+			report.Index, report.Result = i+1, result
+			return report, nil
+		}
+
+		if reference != "" {
+			expected, err := RunCase(reference, c, opts.TimeLimit, opts.Limits)
+			if err != nil {
+				return nil, err
+			}
+			if expected.Verdict != OK {
+				return nil, fmt.Errorf("the reference solution failed on %s: %s", c.Name, expected.Verdict)
+			}
+			result.Expected = expected.Stdout
+			if !Equal(result.Expected, result.Stdout) {
+				result.Verdict = WA
+				report.Index, report.Result = i+1, result
+				return report, nil
+			}
+		}
+		results = append(results, result)
+	}
+	if reference != "" {
+		return report, nil
+	}
+
+	// Find the case which printed the first token differing from the expected output.
+	start := 0
+	expectedTokens := bytes.Fields(failed.Expected)
+	for i, result := range results {
+		end := min(start+len(bytes.Fields(result.Stdout)), len(expectedTokens))
+		if i == len(results)-1 {
+			// The last case is responsible for the rest of the expected output.
+			end = len(expectedTokens)
+		}
+		if !Equal(bytes.Join(expectedTokens[start:end], []byte(" ")), result.Stdout) {
+			result.Expected = expectedLines(failed.Expected, start, end)
+			result.Verdict = WA
+			report.Index, report.Result = i+1, result
+			return report, nil
+		}
+		start = end
+	}
+
+	return report, nil
+}
+
+// expectedLines returns the tokens [start, end) of the output, keeping their line breaks.
+func expectedLines(output []byte, start int, end int) []byte {
+	var b bytes.Buffer
+	index := 0
+	for _, line := range bytes.Split(output, []byte("\n")) {
+		var kept [][]byte
+		for _, token := range bytes.Fields(line) {
+			if start <= index && index < end {
+				kept = append(kept, token)
+			}
+			index++
+		}
+		if len(kept) != 0 {
+			b.Write(bytes.Join(kept, []byte(" ")))
+			b.WriteByte('\n')
+		}
+	}
+
+	return b.Bytes()
+}
@@ -0,0 +1,75 @@
+/*
+Copyright © 2024 lemolatoon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Package judge runs solutions against test cases and gives them verdicts.
+package judge
+
+import (
+	"errors"
+
+	"github.com/lemolatoon/acutils-cli/sandbox"
+	"github.com/lemolatoon/acutils-cli/sanitizer"
+)
+
+// Verdict is the result of running a solution against a test case.
+type Verdict string
+
+const (
+	AC  Verdict = "AC"
+	WA  Verdict = "WA"
+	RE  Verdict = "RE"
+	TLE Verdict = "TLE"
+	MLE Verdict = "MLE"
+	// OK is the verdict of a case without the expected output which runs without errors.
+	OK Verdict = "OK"
+	// CE is the verdict of a solution which fails to compile. It is not given to cases.
+	CE Verdict = "CE"
+)
+
+// Execution is the outcome of running a solution once.
+type Execution struct {
+	// Err is the error returned by running the program, e.g. *exec.ExitError.
+	Err    error
+	Stderr []byte
+	// Report is the sanitizer report found in Stderr, if any.
+	Report *sanitizer.Report
+	// Limit is the sandbox limit the program exceeded, if any.
+	Limit sandbox.Limit
+}
+
+// NewExecution parses the sanitizer report in the stderr of the run.
+func NewExecution(err error, stderr []byte) Execution {
+	execution := Execution{Err: err, Stderr: stderr, Report: sanitizer.Parse(stderr)}
+	var limitErr *sandbox.LimitError
+	if errors.As(err, &limitErr) {
+		execution.Limit = limitErr.Limit
+	}
+
+	return execution
+}
+
+// RuntimeError reports whether the run should be judged as RE.
+// UndefinedBehaviorSanitizer does not stop the program, so a report counts as RE
+// even if the program exits successfully.
+func (e Execution) RuntimeError() bool {
+	return e.Err != nil || e.Report != nil
+}
@@ -0,0 +1,288 @@
+/*
+Copyright © 2024 lemolatoon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Package sandbox runs programs with resource limits (CPU time, address space, file size and
+// the number of processes) in their own process group, so that a runaway solution or generator
+// does not freeze the machine. The limits are applied with Linux rlimits; on other systems only
+// the process group is used. A program reading the terminal is made its foreground process
+// group, so that it can read the terminal and receives Ctrl-C.
+//
+// The rlimits are set before the program is executed: the binary executes itself as a launcher,
+// which sets them and executes the program in its place. Binaries importing the package therefore
+// start as the launcher when they are executed with the limits in the environment.
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits are the resource limits of a program. Zero values mean unlimited.
+type Limits struct {
+	// CPUTime is rounded up to seconds.
+	CPUTime time.Duration
+	// Memory limits the address space in bytes. It cannot be used with AddressSanitizer,
+	// which reserves terabytes of address space for its shadow memory.
+	Memory int64
+	// FileSize limits the size of each file written by the program in bytes.
+	FileSize int64
+	// Processes is the number of processes (and threads) the program may create.
+	Processes int
+	// TempDir runs the program in a fresh temporary working directory, removed after the run.
+	TempDir bool
+}
+
+// Limit is a resource limit which a program exceeded.
+type Limit string
+
+const (
+	LIMIT_CPU       Limit = "CPU time limit"
+	LIMIT_MEMORY    Limit = "memory limit"
+	LIMIT_FILE_SIZE Limit = "file size limit"
+	LIMIT_PROCESSES Limit = "process limit"
+)
+
+// LimitError is the error of a run which exceeded a limit.
+type LimitError struct {
+	Limit Limit
+	// Err is the error of the run, e.g. *exec.ExitError.
+	Err error
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s exceeded (%v)", e.Limit, e.Err)
+}
+
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
+
+// Supported reports whether the limits other than TempDir are enforced on this system.
+const Supported = supported
+
+// LAUNCHER_ENV passes the rlimits to the launcher, e.g. "0=2:3,9=1073741824:1073741824"
+// for the resources RLIMIT_CPU and RLIMIT_AS.
+const LAUNCHER_ENV = "ACUTILS_SANDBOX_RLIMITS"
+
+// Cmd is a command run with the limits.
+type Cmd struct {
+	*exec.Cmd
+	Limits Limits
+
+	stderrTail tail
+}
+
+// Command returns the command to run the program with the limits. The program and the whole
+// process group it creates are killed when ctx is done.
+func Command(ctx context.Context, limits Limits, name string, args ...string) *Cmd {
+	if limits.TempDir && strings.ContainsRune(name, filepath.Separator) {
+		// The program is run in another directory.
+		if abs, err := filepath.Abs(name); err == nil {
+			name = abs
+		}
+	}
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	c := &Cmd{Cmd: cmd, Limits: limits}
+	cmd.Cancel = func() error {
+		return killProcessGroup(cmd)
+	}
+	// Do not wait for the children of the killed process which still hold the pipes.
+	cmd.WaitDelay = 100 * time.Millisecond
+
+	return c
+}
+
+// Run runs the command. If the program exceeded a limit, the error is a *LimitError.
+func (c *Cmd) Run() error {
+	if err := c.Start(); err != nil {
+		return err
+	}
+
+	return c.Wait()
+}
+
+// Start starts the command with the limits. They are set by a launcher before the program
+// is executed, so that it never runs without them.
+func (c *Cmd) Start() error {
+	if c.Limits.TempDir {
+		dir, err := os.MkdirTemp("", "acutils-sandbox-")
+		if err != nil {
+			return err
+		}
+		c.Dir = dir
+	}
+	// Keep the end of stderr to recognize failed allocations.
+	if c.Stderr != nil {
+		c.Stderr = &teeWriter{w: c.Stderr, tail: &c.stderrTail}
+	} else {
+		c.Stderr = &c.stderrTail
+	}
+
+	// The process group depends on whether the program reads the terminal.
+	setProcessGroup(c.Cmd)
+
+	launchWithLimits(c.Cmd, c.Limits)
+
+	if err := c.Cmd.Start(); err != nil {
+		restoreForeground(c.Cmd)
+		c.removeTempDir()
+		return err
+	}
+
+	return nil
+}
+
+// Wait waits for the command, kills the processes left in its process group, gives the
+// terminal back if the program had it and reports the exceeded limit, if any.
+func (c *Cmd) Wait() error {
+	err := c.Cmd.Wait()
+	_ = killProcessGroup(c.Cmd)
+	restoreForeground(c.Cmd)
+	c.removeTempDir()
+	if err == nil {
+		return nil
+	}
+	if limit := c.exceeded(); limit != "" {
+		return &LimitError{Limit: limit, Err: err}
+	}
+
+	return err
+}
+
+// MaxRSS returns the peak resident set size of the finished program in bytes,
+// or 0 if it is unknown.
+func (c *Cmd) MaxRSS() int64 {
+	if c.ProcessState == nil {
+		return 0
+	}
+
+	return maxRSS(c.ProcessState)
+}
+
+func (c *Cmd) removeTempDir() {
+	if c.Limits.TempDir && c.Dir != "" {
+		_ = os.RemoveAll(c.Dir)
+	}
+}
+
+// exceeded guesses the limit the failed program exceeded. CPU time and file size limits
+// are signaled, while the others make allocations or fork fail, which is recognized
+// from the messages of the C++ runtime.
+func (c *Cmd) exceeded() Limit {
+	if limit := signaledLimit(c.ProcessState, c.Limits); limit != "" {
+		return limit
+	}
+	stderr := c.stderrTail.String()
+	if c.Limits.Memory > 0 && (strings.Contains(stderr, "bad_alloc") || strings.Contains(stderr, "Cannot allocate memory") || strings.Contains(stderr, "out of memory")) {
+		return LIMIT_MEMORY
+	}
+	if c.Limits.Processes > 0 && (strings.Contains(stderr, "Resource temporarily unavailable") || strings.Contains(stderr, "std::system_error")) {
+		return LIMIT_PROCESSES
+	}
+
+	return ""
+}
+
+const tailSize = 4096
+
+// tail keeps the last tailSize bytes written to it.
+type tail struct {
+	buf []byte
+}
+
+func (t *tail) Write(p []byte) (int, error) {
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > tailSize {
+		t.buf = t.buf[len(t.buf)-tailSize:]
+	}
+
+	return len(p), nil
+}
+
+func (t *tail) String() string {
+	return string(t.buf)
+}
+
+type teeWriter struct {
+	w    io.Writer
+	tail *tail
+}
+
+func (t *teeWriter) Write(p []byte) (int, error) {
+	_, _ = t.tail.Write(p)
+	return t.w.Write(p)
+}
+
+var sizeSeparators = strings.NewReplacer(" ", "", "_", "")
+
+// ParseSize parses a size such as "1024MB", "1GiB", "512M" or "65536" (bytes).
+// Units are powers of 1024, as judges mean 1024 KiB by 1 MB.
+func ParseSize(s string) (int64, error) {
+	trimmed := strings.ToUpper(sizeSeparators.Replace(s))
+	units := []struct {
+		suffix string
+		shift  uint
+	}{
+		{"GIB", 30}, {"MIB", 20}, {"KIB", 10},
+		{"GB", 30}, {"MB", 20}, {"KB", 10},
+		{"G", 30}, {"M", 20}, {"K", 10},
+		{"B", 0},
+	}
+	shift := uint(0)
+	for _, unit := range units {
+		if strings.HasSuffix(trimmed, unit.suffix) {
+			trimmed = strings.TrimSuffix(trimmed, unit.suffix)
+			shift = unit.shift
+			break
+		}
+	}
+
+	value, err := strconv.ParseInt(trimmed, 10, 64)
+	if err != nil || value < 0 || value > (1<<62)>>shift {
+		return 0, errors.New(`invalid size ` + strconv.Quote(s) + ` (e.g. "1024MB", "1GiB")`)
+	}
+
+	return value << shift, nil
+}
+
+// FormatSize formats the size in the largest unit dividing it, e.g. "1024MiB".
+func FormatSize(size int64) string {
+	for _, unit := range []struct {
+		suffix string
+		shift  uint
+	}{{"GiB", 30}, {"MiB", 20}, {"KiB", 10}} {
+		if size != 0 && size%(1<<unit.shift) == 0 {
+			return fmt.Sprintf("%d%s", size>>unit.shift, unit.suffix)
+		}
+	}
+
+	return fmt.Sprintf("%dB", size)
+}
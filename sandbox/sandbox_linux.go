@@ -0,0 +1,296 @@
+//go:build linux
+
+/*
+Copyright © 2024 lemolatoon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package sandbox
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const supported = true
+
+func setProcessGroup(cmd *exec.Cmd) {
+	if tty, ok := cmd.Stdin.(*os.File); ok && isTerminal(tty) {
+		if !isForeground(tty) {
+			// A background job stays in our process group, as it would be stopped
+			// reading the terminal from any group.
+			cmd.SysProcAttr = nil
+			return
+		}
+		// A background process group is stopped by SIGTTIN when it reads the terminal,
+		// and Ctrl-C is sent only to the foreground one.
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true, Foreground: true, Ctty: int(tty.Fd())}
+		return
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func killProcessGroup(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	if cmd.SysProcAttr == nil || !(cmd.SysProcAttr.Setpgid || cmd.SysProcAttr.Foreground) {
+		return cmd.Process.Kill()
+	}
+	// The process group has the same id as the process leading it.
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
+
+// restoreForeground makes our process group the foreground one again after the program had the terminal.
+func restoreForeground(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil || !cmd.SysProcAttr.Foreground {
+		return
+	}
+	// A background process group is stopped by SIGTTOU when it sets the foreground group.
+	if !signal.Ignored(syscall.SIGTTOU) {
+		signal.Ignore(syscall.SIGTTOU)
+		defer signal.Reset(syscall.SIGTTOU)
+	}
+	_ = unix.IoctlSetPointerInt(cmd.SysProcAttr.Ctty, unix.TIOCSPGRP, unix.Getpgrp())
+}
+
+func isTerminal(file *os.File) bool {
+	_, err := unix.IoctlGetTermios(int(file.Fd()), unix.TCGETS)
+	return err == nil
+}
+
+// isForeground reports whether our process group is the foreground one of the terminal.
+func isForeground(tty *os.File) bool {
+	pgrp, err := unix.IoctlGetInt(int(tty.Fd()), unix.TIOCGPGRP)
+	return err == nil && pgrp == unix.Getpgrp()
+}
+
+func init() {
+	if spec, ok := os.LookupEnv(LAUNCHER_ENV); ok {
+		launch(spec)
+	}
+}
+
+type rlimit struct {
+	resource int
+	unix.Rlimit
+}
+
+func rlimits(limits Limits) []rlimit {
+	var result []rlimit
+	if limits.CPUTime > 0 {
+		seconds := uint64((limits.CPUTime + 999_999_999) / 1_000_000_000)
+		// SIGXCPU at the soft limit, then SIGKILL a second later if it is ignored.
+		result = append(result, rlimit{unix.RLIMIT_CPU, unix.Rlimit{Cur: seconds, Max: seconds + 1}})
+	}
+	if limits.FileSize > 0 {
+		result = append(result, rlimit{unix.RLIMIT_FSIZE, unix.Rlimit{Cur: uint64(limits.FileSize), Max: uint64(limits.FileSize)}})
+	}
+	if limits.Processes > 0 {
+		// RLIMIT_NPROC counts all the processes of the user, not only the children.
+		processes := uint64(countUserProcesses(os.Getuid()) + limits.Processes)
+		result = append(result, rlimit{unix.RLIMIT_NPROC, unix.Rlimit{Cur: processes, Max: processes}})
+	}
+	if limits.Memory > 0 {
+		// The address space is limited last, as the launcher may fail to allocate after it.
+		result = append(result, rlimit{unix.RLIMIT_AS, unix.Rlimit{Cur: uint64(limits.Memory), Max: uint64(limits.Memory)}})
+	}
+
+	return result
+}
+
+// launchWithLimits makes the command execute the launcher, which sets the rlimits and then
+// executes the program.
+func launchWithLimits(cmd *exec.Cmd, limits Limits) {
+	rlimits := rlimits(limits)
+	// A program which is not found is reported by Start.
+	if len(rlimits) == 0 || cmd.Err != nil {
+		return
+	}
+	specs := make([]string, len(rlimits))
+	for i, r := range rlimits {
+		specs[i] = fmt.Sprintf("%d=%d:%d", r.resource, r.Cur, r.Max)
+	}
+
+	cmd.Env = append(cmd.Environ(), LAUNCHER_ENV+"="+strings.Join(specs, ","))
+	// The launcher is executed with the program and its arguments, including its name.
+	cmd.Args = append([]string{"acutils-sandbox", cmd.Path}, cmd.Args...)
+	// /proc/self/exe can be executed even after the binary is replaced, e.g. by go install.
+	cmd.Path = "/proc/self/exe"
+}
+
+func parseRlimits(spec string) ([]rlimit, error) {
+	var result []rlimit
+	for _, field := range strings.Split(spec, ",") {
+		var r rlimit
+		if _, err := fmt.Sscanf(field, "%d=%d:%d", &r.resource, &r.Cur, &r.Max); err != nil {
+			return nil, fmt.Errorf("invalid rlimit %q: %w", field, err)
+		}
+		result = append(result, r)
+	}
+
+	return result, nil
+}
+
+// launch sets the rlimits and executes the program in place of the launcher. It does not return.
+func launch(spec string) {
+	rlimits, err := parseRlimits(spec)
+	if err != nil {
+		launchFailed(err)
+	}
+	if len(os.Args) < 3 {
+		launchFailed(errors.New("no program to execute"))
+	}
+	env := make([]string, 0, len(os.Environ()))
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, LAUNCHER_ENV+"=") {
+			env = append(env, kv)
+		}
+	}
+	// Everything execve needs is allocated before the address space is limited.
+	path, err := syscall.BytePtrFromString(os.Args[1])
+	if err != nil {
+		launchFailed(err)
+	}
+	argv, err := syscall.SlicePtrFromStrings(os.Args[2:])
+	if err != nil {
+		launchFailed(err)
+	}
+	envv, err := syscall.SlicePtrFromStrings(env)
+	if err != nil {
+		launchFailed(err)
+	}
+
+	for _, r := range rlimits {
+		if err := unix.Setrlimit(r.resource, &r.Rlimit); err != nil {
+			launchFailed(fmt.Errorf("failed to apply the resource limits: %w", err))
+		}
+	}
+	_, _, errno := syscall.RawSyscall(syscall.SYS_EXECVE, uintptr(unsafe.Pointer(path)), uintptr(unsafe.Pointer(&argv[0])), uintptr(unsafe.Pointer(&envv[0])))
+	launchFailed(fmt.Errorf("%s: %w", os.Args[1], errno))
+}
+
+// launchFailed exits with the status of a shell failing to execute a command.
+func launchFailed(err error) {
+	fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+	os.Exit(127)
+}
+
+// countUserProcesses counts the processes and threads of the user, which RLIMIT_NPROC limits.
+func countUserProcesses(uid int) int {
+	statuses, _ := filepath.Glob("/proc/[0-9]*/status")
+	count := 0
+	for _, status := range statuses {
+		if processUID(status) == uid {
+			count += processThreads(status)
+		}
+	}
+
+	return count
+}
+
+func processUID(status string) int {
+	return statusField(status, "Uid:")
+}
+
+func processThreads(status string) int {
+	if threads := statusField(status, "Threads:"); threads > 0 {
+		return threads
+	}
+	return 1
+}
+
+// statusField returns the first number of the field in /proc/PID/status, or -1.
+func statusField(status string, name string) int {
+	file, err := os.Open(status)
+	if err != nil {
+		return -1
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) >= 2 && fields[0] == name {
+			value, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return -1
+			}
+			return value
+		}
+	}
+
+	return -1
+}
+
+func signaledLimit(state *os.ProcessState, limits Limits) Limit {
+	if state == nil {
+		return ""
+	}
+	status, ok := state.Sys().(syscall.WaitStatus)
+	if !ok {
+		return ""
+	}
+	var signal syscall.Signal
+	switch {
+	case status.Signaled():
+		signal = status.Signal()
+	case status.Exited() && status.ExitStatus() > 128:
+		// A shell running the program exits with 128+signal when its child is signaled.
+		signal = syscall.Signal(status.ExitStatus() - 128)
+	default:
+		return ""
+	}
+	switch signal {
+	case syscall.SIGXCPU:
+		if limits.CPUTime > 0 {
+			return LIMIT_CPU
+		}
+	case syscall.SIGXFSZ:
+		if limits.FileSize > 0 {
+			return LIMIT_FILE_SIZE
+		}
+	case syscall.SIGKILL:
+		// The hard limit of CPU time is a second after the soft one.
+		if limits.CPUTime > 0 && state.UserTime()+state.SystemTime() >= limits.CPUTime {
+			return LIMIT_CPU
+		}
+	}
+
+	return ""
+}
+
+func maxRSS(state *os.ProcessState) int64 {
+	if usage, ok := state.SysUsage().(*syscall.Rusage); ok {
+		// ru_maxrss is in kilobytes on Linux.
+		return usage.Maxrss * 1024
+	}
+	return 0
+}
@@ -0,0 +1,140 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	cases := map[string]int64{
+		"65536":   65536,
+		"1024MB":  1024 << 20,
+		"1GiB":    1 << 30,
+		"512m":    512 << 20,
+		"256 KiB": 256 << 10,
+		"1_024B":  1024,
+	}
+	for s, want := range cases {
+		got, err := ParseSize(s)
+		if err != nil {
+			t.Errorf("ParseSize(%q) failed: %v", s, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("ParseSize(%q) = %d, want %d", s, got, want)
+		}
+	}
+	for _, s := range []string{"", "MB", "-1MB", "1TB", "1.5GB"} {
+		if _, err := ParseSize(s); err == nil {
+			t.Errorf("ParseSize(%q) should fail", s)
+		}
+	}
+	if got := FormatSize(1024 << 20); got != "1GiB" {
+		t.Errorf("FormatSize(1GiB) = %s", got)
+	}
+	if got := FormatSize(1000); got != "1000B" {
+		t.Errorf("FormatSize(1000) = %s", got)
+	}
+}
+
+func requireSupported(t *testing.T) {
+	t.Helper()
+	if !Supported {
+		t.Skip("resource limits are not supported on this system")
+	}
+}
+
+func TestFileSizeLimitIsReported(t *testing.T) {
+	requireSupported(t)
+	output := filepath.Join(t.TempDir(), "out")
+
+	cmd := Command(context.Background(), Limits{FileSize: 4096}, "sh", "-c", `head -c 1000000 /dev/zero > "$1"`, "sh", output)
+	err := cmd.Run()
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != LIMIT_FILE_SIZE {
+		t.Fatalf("expected the file size limit to be reported, got %v", err)
+	}
+	if info, err := os.Stat(output); err != nil || info.Size() > 4096 {
+		t.Fatalf("expected the output to be truncated at the limit: %v %v", info, err)
+	}
+}
+
+func TestLimitsAreSetBeforeTheProgramStarts(t *testing.T) {
+	requireSupported(t)
+
+	var stdout strings.Builder
+	cmd := Command(context.Background(), Limits{FileSize: 4096, Memory: 1 << 30}, "cat", "/proc/self/limits")
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("failed to run the program: %v", err)
+	}
+	for _, pattern := range []string{`Max file size\s+4096\s+4096\s+bytes`, `Max address space\s+1073741824\s+1073741824\s+bytes`} {
+		if !regexp.MustCompile(pattern).MatchString(stdout.String()) {
+			t.Fatalf("expected the first instruction of the program to run with the limits, got\n%s", stdout.String())
+		}
+	}
+
+	stdout.Reset()
+	cmd = Command(context.Background(), Limits{FileSize: 4096}, "sh", "-c", `echo "${`+LAUNCHER_ENV+`-unset}"`)
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil || stdout.String() != "unset\n" {
+		t.Fatalf("expected the launcher not to pass its environment to the program: %q %v", stdout.String(), err)
+	}
+}
+
+func TestCPUTimeLimitIsReported(t *testing.T) {
+	requireSupported(t)
+
+	start := time.Now()
+	err := Command(context.Background(), Limits{CPUTime: time.Second}, "sh", "-c", "while :; do :; done").Run()
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != LIMIT_CPU {
+		t.Fatalf("expected the CPU time limit to be reported, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("the program was not stopped in time: %s", elapsed)
+	}
+}
+
+func TestProcessGroupIsKilled(t *testing.T) {
+	requireSupported(t)
+	marker := filepath.Join(t.TempDir(), "marker")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	// The background child would create the marker after its parent is killed.
+	err := Command(ctx, Limits{}, "sh", "-c", `(sleep 1; touch "$1") & wait`, "sh", marker).Run()
+	if err == nil {
+		t.Fatalf("expected the program to be killed")
+	}
+	time.Sleep(1500 * time.Millisecond)
+	if _, err := os.Stat(marker); err == nil {
+		t.Fatalf("the child process survived its process group")
+	}
+}
+
+func TestTempDirIsRemoved(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "wd")
+
+	cmd := Command(context.Background(), Limits{TempDir: true}, "sh", "-c", `pwd > "$1"; touch created`, "sh", output)
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	wd, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("failed to read the working directory: %v", err)
+	}
+	dir := filepath.Clean(string(wd[:len(wd)-1]))
+	if dir == filepath.Dir(output) {
+		t.Fatalf("expected a fresh working directory, got %s", dir)
+	}
+	if _, err := os.Stat(dir); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected the working directory to be removed: %v", err)
+	}
+}
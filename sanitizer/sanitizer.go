@@ -0,0 +1,275 @@
+/*
+Copyright © 2024 lemolatoon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Package sanitizer parses AddressSanitizer and UndefinedBehaviorSanitizer reports.
+package sanitizer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Frame is a stack frame of a report.
+type Frame struct {
+	Function string
+	File     string
+	Line     int
+	Column   int
+	// Module and Offset are set for unsymbolized frames, e.g. "(/tmp/a.out+0x53d9)".
+	Module string
+	Offset uint64
+}
+
+// Location returns "file:line:col" of the frame, or "" if it is unsymbolized.
+func (f Frame) Location() string {
+	switch {
+	case f.File == "":
+		return ""
+	case f.Column != 0:
+		return fmt.Sprintf("%s:%d:%d", f.File, f.Line, f.Column)
+	case f.Line != 0:
+		return fmt.Sprintf("%s:%d", f.File, f.Line)
+	default:
+		return f.File
+	}
+}
+
+// Report is the first error reported by a sanitizer.
+type Report struct {
+	// Sanitizer is e.g. "AddressSanitizer" or "UndefinedBehaviorSanitizer".
+	Sanitizer string
+	// Kind is the error kind, e.g. "heap-buffer-overflow" or "signed integer overflow".
+	Kind    string
+	Message string
+	// Access is e.g. "READ of size 4 at 0x602000000040 thread T0".
+	Access string
+	// Frames is the stack trace where the error happened.
+	Frames []Frame
+	// Shadow is the shadow byte at the buggy address with its meaning, e.g. "fa (Heap left redzone)".
+	Shadow string
+}
+
+var (
+	asanErrorPattern   = regexp.MustCompile(`^==\d+==ERROR: (\w+Sanitizer): (.*)$`)
+	summaryPattern     = regexp.MustCompile(`^SUMMARY: (\w+Sanitizer): (\S+)`)
+	ubsanPattern       = regexp.MustCompile(`^(.+?):(\d+):(\d+): runtime error: (.*)$`)
+	accessPattern      = regexp.MustCompile(`^(READ|WRITE) of size \d+`)
+	framePattern       = regexp.MustCompile(`^\s*#\d+ 0x[0-9a-fA-F]+\s+(.*)$`)
+	moduleOffsetSuffix = regexp.MustCompile(`^\((.+)\+0x([0-9a-fA-F]+)\)$`)
+	shadowPattern      = regexp.MustCompile(`^=>.*\[([0-9a-f]{2})\]`)
+	legendPattern      = regexp.MustCompile(`^\s+([A-Za-z][A-Za-z ]+):\s+([0-9a-f ]+)$`)
+)
+
+// Parse finds the first sanitizer report in the stderr of a program.
+// It returns nil if there is no report.
+func Parse(stderr []byte) *Report {
+	var report *Report
+	inFrames, framesDone := false, false
+	shadowCode := ""
+	legend := make(map[string]string)
+
+	scanner := bufio.NewScanner(bytes.NewReader(stderr))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if report == nil {
+			if match := asanErrorPattern.FindStringSubmatch(line); match != nil {
+				kind, _, _ := strings.Cut(match[2], " ")
+				report = &Report{Sanitizer: match[1], Kind: kind, Message: match[2]}
+			} else if match := ubsanPattern.FindStringSubmatch(line); match != nil {
+				lineNumber, _ := strconv.Atoi(match[2])
+				column, _ := strconv.Atoi(match[3])
+				kind, _, _ := strings.Cut(match[4], ":")
+				report = &Report{
+					Sanitizer: "UndefinedBehaviorSanitizer",
+					Kind:      kind,
+					Message:   match[4],
+					Frames:    []Frame{{File: match[1], Line: lineNumber, Column: column}},
+				}
+				framesDone = true
+			}
+			continue
+		}
+
+		switch {
+		case !framesDone && framePattern.MatchString(line):
+			inFrames = true
+			report.Frames = append(report.Frames, parseFrame(framePattern.FindStringSubmatch(line)[1]))
+		case inFrames && !framesDone:
+			framesDone = true
+		case report.Access == "" && accessPattern.MatchString(line):
+			report.Access = line
+		}
+		if match := summaryPattern.FindStringSubmatch(line); match != nil && match[1] == report.Sanitizer {
+			report.Kind = match[2]
+		}
+		if match := shadowPattern.FindStringSubmatch(line); match != nil {
+			shadowCode = match[1]
+		}
+		if match := legendPattern.FindStringSubmatch(line); match != nil {
+			for _, code := range strings.Fields(match[2]) {
+				legend[code] = match[1]
+			}
+		}
+	}
+
+	if report != nil && shadowCode != "" {
+		report.Shadow = shadowCode
+		if meaning, ok := legend[shadowCode]; ok {
+			report.Shadow = fmt.Sprintf("%s (%s)", shadowCode, meaning)
+		}
+	}
+
+	return report
+}
+
+// parseFrame parses a frame after its address, e.g. "in solve() /tmp/main.cpp:5:3"
+// or " (/tmp/a.out+0x53d9)".
+func parseFrame(rest string) Frame {
+	var frame Frame
+	rest = strings.TrimSpace(rest)
+	location := rest
+	if function, ok := strings.CutPrefix(rest, "in "); ok {
+		if i := strings.LastIndex(function, " "); i >= 0 {
+			frame.Function, location = function[:i], function[i+1:]
+		} else {
+			frame.Function, location = function, ""
+		}
+	}
+
+	if match := moduleOffsetSuffix.FindStringSubmatch(location); match != nil {
+		frame.Module = match[1]
+		frame.Offset, _ = strconv.ParseUint(match[2], 16, 64)
+		return frame
+	}
+
+	// location is "file:line:col", "file:line" or "file".
+	parts := strings.Split(location, ":")
+	numbers := []int{}
+	for len(parts) > 1 && len(numbers) < 2 {
+		n, err := strconv.Atoi(parts[len(parts)-1])
+		if err != nil {
+			break
+		}
+		numbers = append([]int{n}, numbers...)
+		parts = parts[:len(parts)-1]
+	}
+	frame.File = strings.Join(parts, ":")
+	if len(numbers) > 0 {
+		frame.Line = numbers[0]
+	}
+	if len(numbers) > 1 {
+		frame.Column = numbers[1]
+	}
+
+	return frame
+}
+
+// Symbolize fills the function and the source location of unsymbolized frames in the executable
+// with addr2line. It is needed when llvm-symbolizer is not found by the sanitizer runtime.
+func (r *Report) Symbolize(executable string) error {
+	var offsets []string
+	var indices []int
+	for i, frame := range r.Frames {
+		if frame.File == "" && frame.Module != "" && sameFile(frame.Module, executable) {
+			offsets = append(offsets, fmt.Sprintf("0x%x", frame.Offset))
+			indices = append(indices, i)
+		}
+	}
+	if len(offsets) == 0 {
+		return nil
+	}
+
+	output, err := exec.Command("addr2line", append([]string{"-f", "-C", "-e", executable}, offsets...)...).Output()
+	if err != nil {
+		return fmt.Errorf("failed to symbolize with addr2line: %w", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	for i, index := range indices {
+		if 2*i+1 >= len(lines) {
+			break
+		}
+		function, location := lines[2*i], lines[2*i+1]
+		// addr2line appends e.g. " (discriminator 2)".
+		location, _, _ = strings.Cut(location, " ")
+		if strings.HasPrefix(location, "??") {
+			continue
+		}
+		symbolized := parseFrame("in " + function + " " + location)
+		symbolized.Module, symbolized.Offset = r.Frames[index].Module, r.Frames[index].Offset
+		r.Frames[index] = symbolized
+	}
+
+	return nil
+}
+
+func sameFile(a string, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+// Fault returns the frame where the error happened, preferring frames in the source file.
+func (r *Report) Fault(sourceFile string) (Frame, bool) {
+	for _, frame := range r.Frames {
+		if frame.File != "" && filepath.Base(frame.File) == filepath.Base(sourceFile) {
+			return frame, true
+		}
+	}
+	for _, frame := range r.Frames {
+		if frame.File != "" {
+			return frame, true
+		}
+	}
+
+	return Frame{}, false
+}
+
+// Headline returns a one-line summary, e.g. "heap-buffer-overflow at main.cpp:42 in solve()".
+func (r *Report) Headline(sourceFile string) string {
+	headline := r.Kind
+	frame, ok := r.Fault(sourceFile)
+	if !ok {
+		return headline
+	}
+	frame.File = filepath.Base(frame.File)
+	headline = fmt.Sprintf("%s at %s", headline, frame.Location())
+	if frame.Function != "" {
+		headline = fmt.Sprintf("%s in %s", headline, shortFunctionName(frame.Function))
+	}
+
+	return headline
+}
+
+// shortFunctionName drops the parameter types, e.g. "solve(std::vector<int>&)" to "solve()".
+func shortFunctionName(function string) string {
+	if i := strings.Index(function, "("); i > 0 {
+		return function[:i] + "()"
+	}
+	return function
+}
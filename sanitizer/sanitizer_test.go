@@ -0,0 +1,74 @@
+package sanitizer
+
+import (
+	"testing"
+)
+
+const asanReport = `=================================================================
+==5702==ERROR: AddressSanitizer: heap-buffer-overflow on address 0x602000000040 at pc 0x5625cc9e33da bp 0x7ffd17417610 sp 0x7ffd17417608
+READ of size 4 at 0x602000000040 thread T0
+    #0 0x5625cc9e33d9 in solve(std::vector<int, std::allocator<int> >&) /home/me/abc001/a/main.cpp:42:11
+    #1 0x5625cc9e35e5 in main /home/me/abc001/a/main.cpp:50
+    #2 0x7f75a2845249  (/lib/x86_64-linux-gnu/libc.so.6+0x27249)
+
+0x602000000040 is located 0 bytes to the right of 16-byte region [0x602000000030,0x602000000040)
+allocated by thread T0 here:
+    #0 0x7f75a2ab9628 in operator new[](unsigned long) ../../../../src/libsanitizer/asan/asan_new_delete.cpp:98
+
+SUMMARY: AddressSanitizer: heap-buffer-overflow /home/me/abc001/a/main.cpp:42:11 in solve(std::vector<int, std::allocator<int> >&)
+Shadow bytes around the buggy address:
+  0x0c047fff7ff0: 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00
+=>0x0c047fff8000: fa fa 00 00 fa fa 00 00[fa]fa fa fa fa fa fa fa
+Shadow byte legend (one shadow byte represents 8 application bytes):
+  Addressable:           00
+  Partially addressable: 01 02 03 04 05 06 07 
+  Heap left redzone:       fa
+  Freed heap region:       fd
+==5702==ABORTING
+`
+
+func TestParseAddressSanitizerReport(t *testing.T) {
+	report := Parse([]byte(asanReport))
+	if report == nil {
+		t.Fatalf("expected a report")
+	}
+	if report.Sanitizer != "AddressSanitizer" || report.Kind != "heap-buffer-overflow" {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if report.Access != "READ of size 4 at 0x602000000040 thread T0" {
+		t.Fatalf("unexpected access: %q", report.Access)
+	}
+	if len(report.Frames) != 3 {
+		t.Fatalf("expected only the frames of the first stack, got %+v", report.Frames)
+	}
+	if report.Frames[2].Module != "/lib/x86_64-linux-gnu/libc.so.6" || report.Frames[2].Offset != 0x27249 {
+		t.Fatalf("unexpected unsymbolized frame: %+v", report.Frames[2])
+	}
+	if report.Shadow != "fa (Heap left redzone)" {
+		t.Fatalf("unexpected shadow summary: %q", report.Shadow)
+	}
+
+	want := "heap-buffer-overflow at main.cpp:42:11 in solve()"
+	if got := report.Headline("a/main.cpp"); got != want {
+		t.Fatalf("headline mismatch:\nwant: %q\ngot : %q", want, got)
+	}
+}
+
+func TestParseUndefinedBehaviorSanitizerReport(t *testing.T) {
+	stderr := "3\nmain.cpp:2:26: runtime error: signed integer overflow: 2147483647 + 1 cannot be represented in type 'int'\n"
+	report := Parse([]byte(stderr))
+	if report == nil {
+		t.Fatalf("expected a report")
+	}
+
+	want := "signed integer overflow at main.cpp:2:26"
+	if got := report.Headline("main.cpp"); got != want {
+		t.Fatalf("headline mismatch:\nwant: %q\ngot : %q", want, got)
+	}
+}
+
+func TestParseWithoutReport(t *testing.T) {
+	if report := Parse([]byte("debug: n = 3\n")); report != nil {
+		t.Fatalf("did not expect a report: %+v", report)
+	}
+}
@@ -19,23 +19,167 @@ LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
 OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
 THE SOFTWARE.
 */
+
+// Package shell runs external commands without a shell, printing each of them as
+// a "+" trace line which can be pasted to a shell as it is.
 package shell
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"strings"
+
+	"github.com/lemolatoon/acutils-cli/sandbox"
 )
 
-func Run(command string) error {
-	fmt.Printf("+%s\n", command)
-	cmd := exec.Command("sh", "-c", command)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Stdin = os.Stdin
-	if err := cmd.Run(); err != nil {
-		return err
+// Cmd is an external command. The arguments are passed to the program as they are,
+// without being interpreted by a shell.
+type Cmd struct {
+	Path string
+	Args []string
+	// StdinFile is a file to use as the stdin, shown as "< file" in the trace line.
+	// It takes precedence over Stdin.
+	StdinFile string
+	Stdin     io.Reader
+	Stdout    io.Writer
+	Stderr    io.Writer
+	// Limits, if set, runs the program in the sandbox with the limits.
+	Limits *sandbox.Limits
+}
+
+// Command returns the command connected to the stdin, stdout and stderr of this process.
+func Command(name string, args ...string) *Cmd {
+	return &Cmd{
+		Path:   name,
+		Args:   args,
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+}
+
+// String returns the command line quoted for a POSIX shell.
+func (c *Cmd) String() string {
+	line := Join(append([]string{c.Path}, c.Args...))
+	if c.StdinFile != "" {
+		line += " < " + Quote(c.StdinFile)
+	}
+
+	return line
+}
+
+// Runner runs commands. Commands take a Runner instead of running commands by themselves,
+// so that they can be printed without being run, or recorded in tests.
+type Runner interface {
+	Run(c *Cmd) error
+}
+
+// ExecRunner runs commands, printing the "+command" trace lines to Trace unless it is nil.
+type ExecRunner struct {
+	Trace io.Writer
+}
+
+func (r ExecRunner) Run(c *Cmd) error {
+	if r.Trace != nil {
+		fmt.Fprintf(r.Trace, "+%s\n", c)
+	}
+
+	var cmd *exec.Cmd
+	var run func() error
+	if c.Limits != nil {
+		sandboxed := sandbox.Command(context.Background(), *c.Limits, c.Path, c.Args...)
+		cmd, run = sandboxed.Cmd, sandboxed.Run
+	} else {
+		cmd = exec.Command(c.Path, c.Args...)
+		run = cmd.Run
+	}
+	cmd.Stdin = c.Stdin
+	if c.StdinFile != "" {
+		stdin, err := os.Open(c.StdinFile)
+		if err != nil {
+			return err
+		}
+		defer stdin.Close()
+		cmd.Stdin = stdin
+	}
+	cmd.Stdout = c.Stdout
+	cmd.Stderr = c.Stderr
+
+	return run()
+}
+
+// DryRunner prints the "+command" trace lines to Out without running the commands.
+type DryRunner struct {
+	Out io.Writer
+}
+
+func (r DryRunner) Run(c *Cmd) error {
+	fmt.Fprintf(r.Out, "+%s\n", c)
+	return nil
+}
+
+// Recorder records the commands without running them, for tests.
+type Recorder struct {
+	Commands []*Cmd
+	// Handle, if set, fakes the command, e.g. by writing to its stdout, and returns its error.
+	Handle func(c *Cmd) error
+}
+
+func (r *Recorder) Run(c *Cmd) error {
+	r.Commands = append(r.Commands, c)
+	if r.Handle != nil {
+		return r.Handle(c)
 	}
 
 	return nil
 }
+
+// Lines returns the recorded commands as trace lines, without "+".
+func (r *Recorder) Lines() []string {
+	lines := make([]string, len(r.Commands))
+	for i, c := range r.Commands {
+		lines[i] = c.String()
+	}
+
+	return lines
+}
+
+// Quote quotes the argument for a POSIX shell if needed.
+func Quote(arg string) string {
+	if arg == "" {
+		return "''"
+	}
+	safe := true
+	for _, r := range arg {
+		if !isSafe(r) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return arg
+	}
+
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+func isSafe(r rune) bool {
+	switch {
+	case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+		return true
+	}
+	return strings.ContainsRune("_-+=@%:,./", r)
+}
+
+// Join quotes the arguments and joins them with spaces.
+func Join(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = Quote(arg)
+	}
+
+	return strings.Join(quoted, " ")
+}
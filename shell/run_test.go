@@ -0,0 +1,119 @@
+package shell
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const hostileName = `my dir; touch pwned $(touch pwned2) 'q" *`
+
+func TestQuote(t *testing.T) {
+	cases := map[string]string{
+		"g++":                "g++",
+		"-std=c++23":         "-std=c++23",
+		"abc300/a/main.cpp":  "abc300/a/main.cpp",
+		"":                   "''",
+		"my dir":             "'my dir'",
+		"it's":               `'it'\''s'`,
+		"$(rm -rf /)":        `'$(rm -rf /)'`,
+		"-fsanitize=address": "-fsanitize=address",
+	}
+	for arg, want := range cases {
+		if got := Quote(arg); got != want {
+			t.Errorf("Quote(%q) = %s, want %s", arg, got, want)
+		}
+	}
+}
+
+func TestCmdStringIsPastableToShell(t *testing.T) {
+	cmd := Command("g++", filepath.Join(hostileName, "main.cpp"), "-o", filepath.Join(hostileName, "a.out"))
+	cmd.StdinFile = "in put.txt"
+
+	want := `g++ 'my dir; touch pwned $(touch pwned2) '\''q" */main.cpp' -o 'my dir; touch pwned $(touch pwned2) '\''q" */a.out' < 'in put.txt'`
+	if got := cmd.String(); got != want {
+		t.Fatalf("trace mismatch:\nwant: %s\ngot : %s", want, got)
+	}
+}
+
+func TestRunPassesHostilePathsAsIs(t *testing.T) {
+	tmp := t.TempDir()
+	origWD, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get wd: %v", err)
+	}
+	defer os.Chdir(origWD)
+	if err := os.Chdir(tmp); err != nil {
+		t.Fatalf("failed to chdir: %v", err)
+	}
+
+	if err := os.Mkdir(hostileName, 0o755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	input := filepath.Join(hostileName, "input.txt")
+	if err := os.WriteFile(input, []byte("hello\n"), 0o644); err != nil {
+		t.Fatalf("failed to write input: %v", err)
+	}
+
+	var stdout bytes.Buffer
+	cmd := Command("cat")
+	cmd.StdinFile = input
+	cmd.Stdout = &stdout
+	if err := (ExecRunner{}).Run(cmd); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if stdout.String() != "hello\n" {
+		t.Fatalf("unexpected stdout: %q", stdout.String())
+	}
+
+	stdout.Reset()
+	cmd = Command("cat", input)
+	cmd.Stdout = &stdout
+	if err := (ExecRunner{}).Run(cmd); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if stdout.String() != "hello\n" {
+		t.Fatalf("unexpected stdout: %q", stdout.String())
+	}
+
+	for _, name := range []string{"pwned", "pwned2"} {
+		if _, err := os.Stat(name); err == nil {
+			t.Fatalf("the path was interpreted by a shell: %s exists", name)
+		}
+	}
+}
+
+func TestDryRunnerAndRecorderDoNotRun(t *testing.T) {
+	tmp := t.TempDir()
+	marker := filepath.Join(tmp, "marker")
+
+	var out bytes.Buffer
+	if err := (DryRunner{Out: &out}).Run(Command("touch", marker)); err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if want := "+touch " + Quote(marker) + "\n"; out.String() != want {
+		t.Fatalf("unexpected dry run output: %q, want %q", out.String(), want)
+	}
+
+	recorder := &Recorder{Handle: func(c *Cmd) error {
+		_, err := c.Stdout.Write([]byte("faked\n"))
+		return err
+	}}
+	var stdout bytes.Buffer
+	cmd := Command("touch", marker)
+	cmd.Stdout = &stdout
+	if err := recorder.Run(cmd); err != nil {
+		t.Fatalf("recorder failed: %v", err)
+	}
+	if lines := recorder.Lines(); len(lines) != 1 || lines[0] != "touch "+Quote(marker) {
+		t.Fatalf("unexpected recorded commands: %q", lines)
+	}
+	if stdout.String() != "faked\n" {
+		t.Fatalf("unexpected faked stdout: %q", stdout.String())
+	}
+
+	if _, err := os.Stat(marker); err == nil {
+		t.Fatalf("the command was run")
+	}
+}
@@ -0,0 +1,238 @@
+/*
+Copyright © 2024 lemolatoon
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Package tomledit edits top-level keys of a TOML document, keeping the rest of
+// the document (comments, ordering, tables) as it is.
+package tomledit
+
+import (
+	"fmt"
+	"strings"
+)
+
+// String returns the TOML basic string of s.
+func String(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+
+	return b.String()
+}
+
+// Array returns the TOML array of the strings.
+func Array(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = String(value)
+	}
+
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// entry is the lines [start, end) of a top-level key/value pair.
+type entry struct {
+	start, end int
+	// comment is the comment following the value on its last line, e.g. " # default".
+	comment string
+}
+
+// Set sets the top-level key to the TOML value, e.g. `"g++"` or `["-O2"]`.
+// An existing key is replaced in place, keeping its trailing comment, and a new key is
+// inserted before the first table. Keys are compared ignoring case.
+func Set(content string, key string, value string) string {
+	lines := strings.Split(content, "\n")
+	if e, ok := find(lines, key); ok {
+		existingKey := strings.TrimSpace(strings.SplitN(lines[e.start], "=", 2)[0])
+		replaced := fmt.Sprintf("%s = %s%s", existingKey, value, e.comment)
+		return strings.Join(splice(lines, e.start, e.end, replaced), "\n")
+	}
+
+	line := fmt.Sprintf("%s = %s", key, value)
+	header := firstTableHeader(lines)
+	if header < 0 {
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		return content + line + "\n"
+	}
+	// Keep the comments and blank lines just above the table with the table.
+	insertAt := header
+	for insertAt > 0 && isBlankOrComment(lines[insertAt-1]) {
+		insertAt--
+	}
+	inserted := []string{line}
+	if insertAt == header {
+		inserted = append(inserted, "")
+	}
+
+	return strings.Join(splice(lines, insertAt, insertAt, inserted...), "\n")
+}
+
+// Unset removes the top-level key. It reports whether the key existed.
+func Unset(content string, key string) (string, bool) {
+	lines := strings.Split(content, "\n")
+	e, ok := find(lines, key)
+	if !ok {
+		return content, false
+	}
+
+	return strings.Join(splice(lines, e.start, e.end), "\n"), true
+}
+
+// Line returns the 1-based line number of the top-level key.
+func Line(content string, key string) (int, bool) {
+	e, ok := find(strings.Split(content, "\n"), key)
+	if !ok {
+		return 0, false
+	}
+
+	return e.start + 1, true
+}
+
+func splice(lines []string, start int, end int, inserted ...string) []string {
+	result := make([]string, 0, len(lines)-(end-start)+len(inserted))
+	result = append(result, lines[:start]...)
+	result = append(result, inserted...)
+	return append(result, lines[end:]...)
+}
+
+func isBlankOrComment(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed == "" || strings.HasPrefix(trimmed, "#")
+}
+
+func isTableHeader(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), "[")
+}
+
+func firstTableHeader(lines []string) int {
+	for i, line := range lines {
+		if isTableHeader(line) {
+			return i
+		}
+	}
+	return -1
+}
+
+// find finds the top-level key, i.e. a key before the first table header.
+func find(lines []string, key string) (entry, bool) {
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if isTableHeader(line) {
+			break
+		}
+		if isBlankOrComment(line) {
+			continue
+		}
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		end, comment := valueEnd(lines, i, value)
+		if strings.EqualFold(unquoteKey(strings.TrimSpace(name)), key) {
+			return entry{start: i, end: end, comment: comment}, true
+		}
+		i = end - 1
+	}
+
+	return entry{}, false
+}
+
+func unquoteKey(key string) string {
+	if len(key) >= 2 && (key[0] == '"' || key[0] == '\'') && key[len(key)-1] == key[0] {
+		return key[1 : len(key)-1]
+	}
+	return key
+}
+
+// valueEnd scans the value starting in lines[start] after "=", which may span lines
+// (arrays and multi-line strings), and returns the line after it and its trailing comment.
+func valueEnd(lines []string, start int, value string) (int, string) {
+	depth := 0
+	var quote string
+	text := value
+	for i := start; i < len(lines); i++ {
+		if i != start {
+			text = lines[i]
+		}
+		for j := 0; j < len(text); j++ {
+			rest := text[j:]
+			if quote != "" {
+				switch {
+				case quote == `"` && rest[0] == '\\', quote == `"""` && rest[0] == '\\':
+					j++
+				case strings.HasPrefix(rest, quote):
+					j += len(quote) - 1
+					quote = ""
+				}
+				continue
+			}
+			switch {
+			case strings.HasPrefix(rest, `"""`), strings.HasPrefix(rest, `'''`):
+				quote = rest[:3]
+				j += 2
+			case rest[0] == '"' || rest[0] == '\'':
+				quote = rest[:1]
+			case rest[0] == '[' || rest[0] == '{':
+				depth++
+			case rest[0] == ']' || rest[0] == '}':
+				depth--
+			case rest[0] == '#':
+				if depth == 0 {
+					comment := text[j:]
+					// Keep the spaces before the comment.
+					k := j
+					for k > 0 && (text[k-1] == ' ' || text[k-1] == '\t') {
+						k--
+					}
+					return i + 1, text[k:j] + comment
+				}
+				j = len(text)
+			}
+		}
+		if depth <= 0 && quote == "" {
+			return i + 1, ""
+		}
+	}
+
+	return len(lines), ""
+}
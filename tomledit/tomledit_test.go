@@ -0,0 +1,101 @@
+package tomledit
+
+import (
+	"testing"
+)
+
+const document = `# my settings
+CXX = "g++-12" # judge compiler
+CXXFLAGS = [
+  "-O2", # optimize
+  "-std=c++23",
+]
+
+# template values
+[TEMPLATE_VARS]
+cxx = "in a table"
+`
+
+func TestSetReplacesExistingKey(t *testing.T) {
+	got := Set(document, "cxx", String("clang++"))
+	want := `# my settings
+CXX = "clang++" # judge compiler
+CXXFLAGS = [
+  "-O2", # optimize
+  "-std=c++23",
+]
+
+# template values
+[TEMPLATE_VARS]
+cxx = "in a table"
+`
+	if got != want {
+		t.Fatalf("document mismatch:\nwant: %q\ngot : %q", want, got)
+	}
+
+	got = Set(document, "CXXFLAGS", Array([]string{"-g"}))
+	want = `# my settings
+CXX = "g++-12" # judge compiler
+CXXFLAGS = ["-g"]
+
+# template values
+[TEMPLATE_VARS]
+cxx = "in a table"
+`
+	if got != want {
+		t.Fatalf("document mismatch:\nwant: %q\ngot : %q", want, got)
+	}
+}
+
+func TestSetInsertsBeforeFirstTable(t *testing.T) {
+	got := Set(document, "TIME_LIMIT", String("3s"))
+	want := `# my settings
+CXX = "g++-12" # judge compiler
+CXXFLAGS = [
+  "-O2", # optimize
+  "-std=c++23",
+]
+TIME_LIMIT = "3s"
+
+# template values
+[TEMPLATE_VARS]
+cxx = "in a table"
+`
+	if got != want {
+		t.Fatalf("document mismatch:\nwant: %q\ngot : %q", want, got)
+	}
+
+	if got := Set("", "AUTHOR", String(`a "quoted"\name`)); got != "AUTHOR = \"a \\\"quoted\\\"\\\\name\"\n" {
+		t.Fatalf("unexpected new document: %q", got)
+	}
+}
+
+func TestUnset(t *testing.T) {
+	got, ok := Unset(document, "CXXFLAGS")
+	if !ok {
+		t.Fatalf("expected CXXFLAGS to be removed")
+	}
+	want := `# my settings
+CXX = "g++-12" # judge compiler
+
+# template values
+[TEMPLATE_VARS]
+cxx = "in a table"
+`
+	if got != want {
+		t.Fatalf("document mismatch:\nwant: %q\ngot : %q", want, got)
+	}
+
+	if _, ok := Unset(document, "AUTHOR"); ok {
+		t.Fatalf("did not expect a missing key to be removed")
+	}
+}
+
+func TestLine(t *testing.T) {
+	if line, ok := Line(document, "cxxflags"); !ok || line != 3 {
+		t.Fatalf("Line(CXXFLAGS) = %d, %v", line, ok)
+	}
+	if _, ok := Line(document, "cxx2"); ok {
+		t.Fatalf("did not expect a line for a missing key")
+	}
+}